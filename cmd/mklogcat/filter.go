@@ -0,0 +1,130 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/zhongxuqi/mklibs/mklog"
+)
+
+type filterOp int
+
+const (
+	filterOpEqual filterOp = iota
+	filterOpNotEqual
+	filterOpMatch
+	filterOpNotMatch
+)
+
+// filterExpr is a single key/value condition. Keys are msg, caller, logid, level
+// or the name of a record field.
+type filterExpr struct {
+	key    string
+	op     filterOp
+	value  string
+	regexp *regexp.Regexp
+}
+
+type filter struct {
+	level mklog.Level
+	logID string
+	since time.Time
+	until time.Time
+	exprs []filterExpr
+}
+
+// parseFilter parses a comma separated list of key=value, key!=value, key~regexp
+// and key!~regexp expressions. All expressions must match.
+func parseFilter(expr string) (*filter, error) {
+	res := &filter{}
+	if strings.TrimSpace(expr) == "" {
+		return res, nil
+	}
+	for _, item := range strings.Split(expr, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		var e filterExpr
+		if idx := strings.IndexAny(item, "!=~"); idx <= 0 {
+			return nil, fmt.Errorf("invalid filter expression %s", item)
+		} else {
+			e.key = item[:idx]
+			rest := item[idx:]
+			switch {
+			case strings.HasPrefix(rest, "!="):
+				e.op, e.value = filterOpNotEqual, rest[2:]
+			case strings.HasPrefix(rest, "!~"):
+				e.op, e.value = filterOpNotMatch, rest[2:]
+			case strings.HasPrefix(rest, "="):
+				e.op, e.value = filterOpEqual, rest[1:]
+			case strings.HasPrefix(rest, "~"):
+				e.op, e.value = filterOpMatch, rest[1:]
+			default:
+				return nil, fmt.Errorf("invalid filter expression %s", item)
+			}
+		}
+		if e.op == filterOpMatch || e.op == filterOpNotMatch {
+			re, err := regexp.Compile(e.value)
+			if err != nil {
+				return nil, fmt.Errorf("invalid filter expression %s: %+v", item, err)
+			}
+			e.regexp = re
+		}
+		res.exprs = append(res.exprs, e)
+	}
+	return res, nil
+}
+
+func (s *filter) match(record *mklog.Record) bool {
+	if record.Level < s.level {
+		return false
+	}
+	if s.logID != "" && record.LogID != s.logID {
+		return false
+	}
+	if !s.since.IsZero() && record.Time.Before(s.since) {
+		return false
+	}
+	if !s.until.IsZero() && !record.Time.Before(s.until) {
+		return false
+	}
+	for _, e := range s.exprs {
+		value, ok := recordValue(record, e.key)
+		var matched bool
+		switch e.op {
+		case filterOpEqual:
+			matched = ok && value == e.value
+		case filterOpNotEqual:
+			matched = !ok || value != e.value
+		case filterOpMatch:
+			matched = ok && e.regexp.MatchString(value)
+		case filterOpNotMatch:
+			matched = !ok || !e.regexp.MatchString(value)
+		}
+		if !matched {
+			return false
+		}
+	}
+	return true
+}
+
+func recordValue(record *mklog.Record, key string) (string, bool) {
+	switch key {
+	case "msg":
+		return record.Message, true
+	case "caller":
+		return record.Caller, true
+	case "logid":
+		return record.LogID, true
+	case "level":
+		return mklog.LevelMap[record.Level], true
+	}
+	v, ok := record.Fields[key]
+	if !ok {
+		return "", false
+	}
+	return fmt.Sprintf("%+v", v), true
+}
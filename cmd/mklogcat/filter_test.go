@@ -0,0 +1,55 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/zhongxuqi/mklibs/mklog"
+)
+
+func TestFilter(t *testing.T) {
+	f, err := parseFilter("msg~^time, errno!=0 ,level=Error")
+	if err != nil {
+		t.Fatalf("parseFilter error %+v", err)
+	} else if len(f.exprs) != 3 {
+		t.Fatalf("parseFilter data error %+v", f)
+	}
+	record := &mklog.Record{
+		Level:   mklog.LevelError,
+		Message: "timeout",
+		Fields:  map[string]interface{}{"errno": 1},
+	}
+	if !f.match(record) {
+		t.Fatalf("filter should match %+v", record)
+	}
+	record.Fields["errno"] = 0
+	if f.match(record) {
+		t.Fatalf("filter should not match %+v", record)
+	}
+
+	// 文本格式的记录按字段过滤
+	record, err = mklog.ParseRecord((&mklog.Record{
+		Time:    time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC),
+		Level:   mklog.LevelInfo,
+		LogID:   "id",
+		Caller:  "a.go:1",
+		Message: "query user",
+		Fields:  map[string]interface{}{"uid": 7, "errmsg": "not found"},
+	}).FormatText(true))
+	if err != nil {
+		t.Fatalf("mklog.ParseRecord error %+v", err)
+	}
+	if f, _ = parseFilter("uid=7,errmsg=not found,msg=query user"); !f.match(record) {
+		t.Fatalf("filter should match %+v", record)
+	}
+	if f, _ = parseFilter("uid!=7"); f.match(record) {
+		t.Fatalf("filter should not match %+v", record)
+	}
+
+	if _, err := parseFilter("=value"); err == nil {
+		t.Fatalf("parseFilter should fail")
+	}
+	if _, err := parseFilter("msg~("); err == nil {
+		t.Fatalf("parseFilter should fail")
+	}
+}
@@ -0,0 +1,205 @@
+// Command mklogcat pretty-prints mklog output (text or JSON lines) from files or stdin.
+//
+//	mklogcat [-level Info] [-logid ID] [-since 10m] [-until 2006-01-02T15:04:05Z]
+//	         [-filter 'msg~timeout,errno!=0'] [-f] [-json] [-no-color] [file ...]
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/zhongxuqi/mklibs/mklog"
+)
+
+var (
+	flagLevel   = flag.String("level", "Debug", "minimum level to print: Debug, Info or Error")
+	flagLogID   = flag.String("logid", "", "only print records with this log id")
+	flagSince   = flag.String("since", "", "only print records at or after this time (RFC3339, or a duration such as 10m meaning that long ago)")
+	flagUntil   = flag.String("until", "", "only print records before this time (RFC3339, or a duration such as 10m meaning that long ago)")
+	flagFilter  = flag.String("filter", "", "comma separated field expressions: key=value, key!=value, key~regexp, key!~regexp")
+	flagFollow  = flag.Bool("f", false, "keep reading files as they grow, like tail -f")
+	flagJSON    = flag.Bool("json", false, "print records as JSON lines")
+	flagNoColor = flag.Bool("no-color", false, "disable colorized output")
+
+	followInterval = 200 * time.Millisecond
+)
+
+type printer struct {
+	mutex  sync.Mutex
+	out    io.Writer
+	filter *filter
+	json   bool
+	color  bool
+}
+
+func (s *printer) print(record *mklog.Record) {
+	if !s.filter.match(record) {
+		return
+	}
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if s.json {
+		b, err := json.Marshal(record)
+		if err == nil {
+			fmt.Fprintf(s.out, "%s\n", b)
+			return
+		}
+	}
+	fmt.Fprintf(s.out, "%s\n", record.FormatText(s.color))
+}
+
+func main() {
+	flag.Parse()
+	f, err := newFilterFromFlags(time.Now())
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "mklogcat: %+v\n", err)
+		os.Exit(2)
+	}
+	p := &printer{
+		out:    os.Stdout,
+		filter: f,
+		json:   *flagJSON,
+		color:  !*flagNoColor && !mklog.NoColor,
+	}
+	if flag.NArg() == 0 {
+		if err := cat(os.Stdin, p); err != nil {
+			fmt.Fprintf(os.Stderr, "mklogcat: %+v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+	exitCode := 0
+	var wg sync.WaitGroup
+	for _, fileName := range flag.Args() {
+		if *flagFollow {
+			wg.Add(1)
+			go func(fileName string) {
+				defer wg.Done()
+				if err := follow(fileName, p); err != nil {
+					fmt.Fprintf(os.Stderr, "mklogcat: %+v\n", err)
+				}
+			}(fileName)
+			continue
+		}
+		if err := catFile(fileName, p); err != nil {
+			fmt.Fprintf(os.Stderr, "mklogcat: %+v\n", err)
+			exitCode = 1
+		}
+	}
+	wg.Wait()
+	os.Exit(exitCode)
+}
+
+func newFilterFromFlags(now time.Time) (*filter, error) {
+	f, err := parseFilter(*flagFilter)
+	if err != nil {
+		return nil, err
+	}
+	if f.level, err = mklog.ParseLevel(*flagLevel); err != nil {
+		return nil, err
+	}
+	f.logID = *flagLogID
+	if f.since, err = parseTime(*flagSince, now); err != nil {
+		return nil, err
+	}
+	if f.until, err = parseTime(*flagUntil, now); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+// parseTime accepts an RFC3339 time or a duration relative to now.
+func parseTime(value string, now time.Time) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+	if d, err := time.ParseDuration(value); err == nil {
+		return now.Add(-d), nil
+	}
+	t, err := time.Parse(time.RFC3339Nano, value)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid time %s", value)
+	}
+	return t, nil
+}
+
+func catFile(fileName string, p *printer) error {
+	file, err := os.Open(fileName)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	return cat(file, p)
+}
+
+func cat(r io.Reader, p *printer) error {
+	reader := mklog.NewRecordReader(r)
+	for {
+		record, err := reader.Next()
+		if err == io.EOF {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		p.print(record)
+	}
+}
+
+// follow prints the whole file and then polls it for appended lines. The file is
+// read again from the start when it is truncated or rotated.
+func follow(fileName string, p *printer) error {
+	file, err := os.Open(fileName)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		file.Close()
+	}()
+	reader := bufio.NewReader(file)
+	records := mklog.NewRecordReader(nil)
+	var offset int64
+	partial := ""
+	for {
+		line, err := reader.ReadString('\n')
+		offset += int64(len(line))
+		partial += line
+		if err == nil {
+			if record := records.Add(partial); record != nil {
+				p.print(record)
+			}
+			partial = ""
+			continue
+		} else if err != io.EOF {
+			return err
+		}
+		// a record is written at once, the one being read is complete at the end of the file
+		if partial == "" {
+			if record := records.Flush(); record != nil {
+				p.print(record)
+			}
+		}
+		time.Sleep(followInterval)
+		info, err := os.Stat(fileName)
+		if err != nil {
+			continue
+		}
+		if currInfo, err := file.Stat(); err == nil && (!os.SameFile(info, currInfo) || info.Size() < offset) {
+			newFile, err := os.Open(fileName)
+			if err != nil {
+				continue
+			}
+			file.Close()
+			file = newFile
+			reader.Reset(file)
+			records.Flush()
+			offset = 0
+			partial = ""
+		}
+	}
+}
@@ -0,0 +1,252 @@
+// Command mklogtrace collects the records of one log id from the mklog files of
+// several services and prints them as a single timeline, including the outbound
+// calls made through mkhttpclient and their latencies.
+//
+//	mklogtrace [-no-color] <logid> [service=]file-or-dir ...
+//
+// The service name defaults to the directory name for a directory argument and
+// to the file name without extension for a file argument.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/zhongxuqi/mklibs/common"
+	"github.com/zhongxuqi/mklibs/mklog"
+)
+
+const (
+	colorNone = "\x1b[0m"
+	colorBlue = "\x1b[1;34m"
+	colorCyan = "\x1b[1;36m"
+)
+
+var (
+	flagNoColor = flag.Bool("no-color", false, "disable colorized output")
+)
+
+type source struct {
+	service string
+	path    string
+}
+
+type traceRecord struct {
+	*mklog.Record
+	service string
+	call    *outboundCall
+}
+
+type outboundCall struct {
+	method  string
+	url     string
+	status  string
+	latency time.Duration
+	attempt string
+	failed  bool
+}
+
+func main() {
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "usage: mklogtrace [-no-color] <logid> [service=]file-or-dir ...\n")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if flag.NArg() < 2 {
+		flag.Usage()
+		os.Exit(2)
+	}
+	logID := flag.Arg(0)
+	sources, err := collectSources(flag.Args()[1:])
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "mklogtrace: %+v\n", err)
+		os.Exit(1)
+	}
+	records := make([]*traceRecord, 0)
+	for _, src := range sources {
+		fileRecords, err := readSource(src, logID)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "mklogtrace: %+v\n", err)
+			continue
+		}
+		records = append(records, fileRecords...)
+	}
+	if len(records) == 0 {
+		fmt.Fprintf(os.Stderr, "mklogtrace: no record with log id %s\n", logID)
+		os.Exit(1)
+	}
+	sortRecords(records)
+	printTimeline(os.Stdout, logID, records, !*flagNoColor && !mklog.NoColor)
+}
+
+// collectSources expands the arguments into the list of files to read. Files of a
+// directory argument are read recursively and share the directory's service name.
+func collectSources(args []string) ([]source, error) {
+	res := make([]source, 0, len(args))
+	for _, arg := range args {
+		service, path := "", arg
+		if idx := strings.Index(arg, "="); idx > 0 {
+			service, path = arg[:idx], arg[idx+1:]
+		}
+		info, err := os.Stat(path)
+		if err != nil {
+			return nil, err
+		}
+		if !info.IsDir() {
+			if service == "" {
+				service = strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+			}
+			res = append(res, source{service: service, path: path})
+			continue
+		}
+		if service == "" {
+			service = filepath.Base(filepath.Clean(path))
+		}
+		err = filepath.Walk(path, func(filePath string, fileInfo os.FileInfo, err error) error {
+			if err != nil {
+				return err
+			}
+			if fileInfo.Mode().IsRegular() {
+				res = append(res, source{service: service, path: filePath})
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	return res, nil
+}
+
+func readSource(src source, logID string) ([]*traceRecord, error) {
+	file, err := os.Open(src.path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	res := make([]*traceRecord, 0)
+	reader := mklog.NewRecordReader(file)
+	for {
+		record, err := reader.Next()
+		if err == io.EOF {
+			return res, nil
+		} else if err != nil {
+			return nil, err
+		}
+		if record.LogID != logID {
+			continue
+		}
+		res = append(res, newTraceRecord(record, src.service))
+	}
+}
+
+func newTraceRecord(record *mklog.Record, service string) *traceRecord {
+	fields := make(map[string]string, len(record.Fields))
+	for k, v := range record.Fields {
+		fields[k] = fmt.Sprintf("%+v", v)
+	}
+	res := &traceRecord{
+		Record:  record,
+		service: service,
+	}
+	if fields[common.LogFieldHttpURL] == "" || fields[common.LogFieldHttpLatency] == "" {
+		return res
+	}
+	latency, _ := strconv.ParseFloat(fields[common.LogFieldHttpLatency], 64)
+	res.call = &outboundCall{
+		method:  fields[common.LogFieldHttpMethod],
+		url:     fields[common.LogFieldHttpURL],
+		status:  fields[common.LogFieldHttpStatus],
+		latency: time.Duration(latency * float64(time.Millisecond)),
+		attempt: fields[common.LogFieldHttpAttempt],
+		failed:  record.Level == mklog.LevelError,
+	}
+	return res
+}
+
+// sortRecords orders records by time, keeping the file order of records logged
+// at the same time.
+func sortRecords(records []*traceRecord) {
+	sort.SliceStable(records, func(i, j int) bool {
+		return records[i].Time.Before(records[j].Time)
+	})
+}
+
+func printTimeline(out io.Writer, logID string, records []*traceRecord, color bool) {
+	start := records[0].Time
+	end := records[len(records)-1].Time
+	services := make([]string, 0)
+	serviceWidth := 0
+	for _, record := range records {
+		if len(record.service) > serviceWidth {
+			serviceWidth = len(record.service)
+		}
+		found := false
+		for _, service := range services {
+			if service == record.service {
+				found = true
+				break
+			}
+		}
+		if !found {
+			services = append(services, record.service)
+		}
+	}
+	fmt.Fprintf(out, "trace %s: %d records, services %s, %s - %s (%s)\n", logID, len(records),
+		strings.Join(services, ", "), start.Format(mklog.TimeFormat), end.Format(mklog.TimeFormat), end.Sub(start))
+
+	calls := make([]*traceRecord, 0)
+	for _, record := range records {
+		offset := fmt.Sprintf("+%8.3fs", record.Time.Sub(start).Seconds())
+		level := fmt.Sprintf("%-5s", mklog.LevelMap[record.Level])
+		if color {
+			offset = colorBlue + offset + colorNone
+			level = mklog.LevelColorMap[record.Level] + level + colorNone
+		}
+		fmt.Fprintf(out, "%s %-*s %s %s: %s\n", offset, serviceWidth, record.service, level, record.Caller,
+			strings.TrimSpace(record.Message))
+		if record.call == nil {
+			continue
+		}
+		calls = append(calls, record)
+		callLine := fmt.Sprintf("%s %s", formatCall(record.call), record.call.latency)
+		if color {
+			callLine = colorCyan + callLine + colorNone
+		}
+		fmt.Fprintf(out, "%*s -> %s\n", 10+serviceWidth, "", callLine)
+	}
+
+	if len(calls) == 0 {
+		return
+	}
+	var total time.Duration
+	slowest := calls[0]
+	for _, call := range calls {
+		total += call.call.latency
+		if call.call.latency > slowest.call.latency {
+			slowest = call
+		}
+	}
+	fmt.Fprintf(out, "outbound calls: %d, total latency %s, slowest %s %s from %s\n", len(calls), total,
+		formatCall(slowest.call), slowest.call.latency, slowest.service)
+}
+
+func formatCall(call *outboundCall) string {
+	res := fmt.Sprintf("%s %s", call.method, call.url)
+	if call.status != "" {
+		res += " " + call.status
+	} else if call.failed {
+		res += " failed"
+	}
+	if call.attempt != "" && call.attempt != "1" {
+		res += " attempt " + call.attempt
+	}
+	return res
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/zhongxuqi/mklibs/common"
+	"github.com/zhongxuqi/mklibs/mklog"
+)
+
+func TestTrace(t *testing.T) {
+	dir := t.TempDir()
+	start := time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC)
+	writeRecords := func(path string, json bool, records ...*mklog.Record) {
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatalf("os.MkdirAll error %+v", err)
+		}
+		buf := bytes.NewBuffer(nil)
+		for _, record := range records {
+			if json {
+				b, _ := record.MarshalJSON()
+				buf.Write(b)
+			} else {
+				buf.WriteString(record.FormatText(false))
+			}
+			buf.WriteString("\n")
+		}
+		if err := os.WriteFile(path, buf.Bytes(), 0644); err != nil {
+			t.Fatalf("os.WriteFile error %+v", err)
+		}
+	}
+	writeRecords(filepath.Join(dir, "gateway", "app.log"), true,
+		&mklog.Record{Time: start, Level: mklog.LevelInfo, LogID: "trace", Caller: "gw.go:1", Message: "request in"},
+		&mklog.Record{Time: start.Add(30 * time.Millisecond), Level: mklog.LevelInfo, LogID: "trace", Caller: "client.go:1",
+			Message: "http call finished", Fields: map[string]interface{}{
+				common.LogFieldHttpMethod:  "GET",
+				common.LogFieldHttpURL:     "http://user/info",
+				common.LogFieldHttpStatus:  200,
+				common.LogFieldHttpLatency: 25,
+				common.LogFieldHttpAttempt: 1,
+			}},
+		&mklog.Record{Time: start.Add(time.Millisecond), Level: mklog.LevelInfo, LogID: "other", Caller: "gw.go:1", Message: "other request"},
+	)
+	writeRecords(filepath.Join(dir, "user.log"), false,
+		&mklog.Record{Time: start.Add(10 * time.Millisecond), Level: mklog.LevelError, LogID: "trace", Caller: "user.go:1", Message: "query user"},
+		&mklog.Record{Time: start.Add(15 * time.Millisecond), Level: mklog.LevelInfo, LogID: "trace", Caller: "client.go:1",
+			Message: "http call finished", Fields: map[string]interface{}{
+				common.LogFieldHttpMethod:  "POST",
+				common.LogFieldHttpURL:     "http://db/query",
+				common.LogFieldHttpStatus:  200,
+				common.LogFieldHttpLatency: 3.5,
+				common.LogFieldHttpAttempt: 1,
+			}},
+	)
+
+	sources, err := collectSources([]string{filepath.Join(dir, "gateway"), "svc=" + filepath.Join(dir, "user.log")})
+	if err != nil {
+		t.Fatalf("collectSources error %+v", err)
+	} else if len(sources) != 2 || sources[0].service != "gateway" || sources[1].service != "svc" {
+		t.Fatalf("collectSources data error %+v", sources)
+	}
+	records := make([]*traceRecord, 0)
+	for _, src := range sources {
+		fileRecords, err := readSource(src, "trace")
+		if err != nil {
+			t.Fatalf("readSource error %+v", err)
+		}
+		records = append(records, fileRecords...)
+	}
+	sortRecords(records)
+	// 文本格式的记录也解析出字段
+	if len(records) != 4 || records[1].service != "svc" || records[3].call == nil || records[3].call.latency != 25*time.Millisecond ||
+		records[2].call == nil || records[2].call.url != "http://db/query" || records[2].Message != "http call finished" {
+		t.Fatalf("records data error %+v", records)
+	}
+
+	out := bytes.NewBuffer(nil)
+	printTimeline(out, "trace", records, false)
+	if !strings.Contains(out.String(), "-> GET http://user/info 200 25ms") ||
+		!strings.Contains(out.String(), "-> POST http://db/query 200 3.5ms") {
+		t.Fatalf("printTimeline data error\n%s", out.String())
+	}
+}
@@ -2,4 +2,11 @@ package common
 
 const (
 	HttpLogID = "http-mklog-id"
+
+	// log fields of the outbound calls made by mkhttpclient
+	LogFieldHttpMethod  = "http_method"
+	LogFieldHttpURL     = "http_url"
+	LogFieldHttpStatus  = "http_status"
+	LogFieldHttpLatency = "http_latency_ms"
+	LogFieldHttpAttempt = "http_attempt"
 )
@@ -1,11 +1,11 @@
-module github.com/zhongxuqi
+module github.com/zhongxuqi/mklibs
 
-go 1.18
+go 1.20
 
 require (
 	github.com/google/uuid v1.3.0
 	github.com/mattn/go-isatty v0.0.14
-	github.com/zhongxuqi/mklibs v1.0.3
+	google.golang.org/grpc v1.47.0
 )
 
 require golang.org/x/sys v0.0.0-20210630005230-0f9fa26af87c // indirect
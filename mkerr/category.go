@@ -0,0 +1,329 @@
+package mkerr
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+
+	"google.golang.org/grpc/codes"
+)
+
+// Category is the canonical class of an error, deciding how callers react to it
+// whatever its errno.
+type Category int
+
+const (
+	CategoryUnknown Category = iota
+	CategoryInvalidArgument
+	CategoryNotFound
+	CategoryAlreadyExists
+	CategoryPermissionDenied
+	CategoryUnauthenticated
+	CategoryResourceExhausted
+	CategoryFailedPrecondition
+	CategoryCanceled
+	CategoryTimeout
+	CategoryUnavailable
+	CategoryInternal
+)
+
+var (
+	categoryNames = map[Category]string{
+		CategoryUnknown:            "unknown",
+		CategoryInvalidArgument:    "invalid_argument",
+		CategoryNotFound:           "not_found",
+		CategoryAlreadyExists:      "already_exists",
+		CategoryPermissionDenied:   "permission_denied",
+		CategoryUnauthenticated:    "unauthenticated",
+		CategoryResourceExhausted:  "resource_exhausted",
+		CategoryFailedPrecondition: "failed_precondition",
+		CategoryCanceled:           "canceled",
+		CategoryTimeout:            "timeout",
+		CategoryUnavailable:        "unavailable",
+		CategoryInternal:           "internal",
+	}
+
+	categoryHTTPStatus = map[Category]int{
+		CategoryUnknown:            http.StatusInternalServerError,
+		CategoryInvalidArgument:    http.StatusBadRequest,
+		CategoryNotFound:           http.StatusNotFound,
+		CategoryAlreadyExists:      http.StatusConflict,
+		CategoryPermissionDenied:   http.StatusForbidden,
+		CategoryUnauthenticated:    http.StatusUnauthorized,
+		CategoryResourceExhausted:  http.StatusTooManyRequests,
+		CategoryFailedPrecondition: http.StatusPreconditionFailed,
+		CategoryCanceled:           499,
+		CategoryTimeout:            http.StatusGatewayTimeout,
+		CategoryUnavailable:        http.StatusServiceUnavailable,
+		CategoryInternal:           http.StatusInternalServerError,
+	}
+
+	categoryGRPCCode = map[Category]codes.Code{
+		CategoryUnknown:            codes.Unknown,
+		CategoryInvalidArgument:    codes.InvalidArgument,
+		CategoryNotFound:           codes.NotFound,
+		CategoryAlreadyExists:      codes.AlreadyExists,
+		CategoryPermissionDenied:   codes.PermissionDenied,
+		CategoryUnauthenticated:    codes.Unauthenticated,
+		CategoryResourceExhausted:  codes.ResourceExhausted,
+		CategoryFailedPrecondition: codes.FailedPrecondition,
+		CategoryCanceled:           codes.Canceled,
+		CategoryTimeout:            codes.DeadlineExceeded,
+		CategoryUnavailable:        codes.Unavailable,
+		CategoryInternal:           codes.Internal,
+	}
+)
+
+func (c Category) String() string {
+	if name, ok := categoryNames[c]; ok {
+		return name
+	}
+	return fmt.Sprintf("category(%d)", int(c))
+}
+
+// HTTPStatus returns the HTTP status usually answered for the category.
+func (c Category) HTTPStatus() int {
+	if status, ok := categoryHTTPStatus[c]; ok {
+		return status
+	}
+	return http.StatusInternalServerError
+}
+
+// GRPCCode returns the gRPC code of the category.
+func (c Category) GRPCCode() codes.Code {
+	if code, ok := categoryGRPCCode[c]; ok {
+		return code
+	}
+	return codes.Unknown
+}
+
+// Retryable reports whether errors of the category are worth retrying.
+func (c Category) Retryable() bool {
+	return c == CategoryUnavailable || c == CategoryTimeout || c == CategoryResourceExhausted
+}
+
+func (c Category) MarshalText() ([]byte, error) {
+	return []byte(c.String()), nil
+}
+
+func (c *Category) UnmarshalText(b []byte) error {
+	for category, name := range categoryNames {
+		if name == string(b) {
+			*c = category
+			return nil
+		}
+	}
+	*c = CategoryUnknown
+	return nil
+}
+
+// CategoryFromHTTPStatus returns the category of an HTTP error status, or
+// CategoryUnknown for other statuses.
+func CategoryFromHTTPStatus(status int) Category {
+	switch status {
+	case http.StatusBadRequest:
+		return CategoryInvalidArgument
+	case http.StatusUnauthorized:
+		return CategoryUnauthenticated
+	case http.StatusForbidden:
+		return CategoryPermissionDenied
+	case http.StatusNotFound:
+		return CategoryNotFound
+	case http.StatusConflict:
+		return CategoryAlreadyExists
+	case http.StatusPreconditionFailed:
+		return CategoryFailedPrecondition
+	case http.StatusTooManyRequests:
+		return CategoryResourceExhausted
+	case 499:
+		return CategoryCanceled
+	case http.StatusRequestTimeout, http.StatusGatewayTimeout:
+		return CategoryTimeout
+	case http.StatusBadGateway, http.StatusServiceUnavailable:
+		return CategoryUnavailable
+	}
+	if status/100 == 5 {
+		return CategoryInternal
+	}
+	return CategoryUnknown
+}
+
+// Category returns the category given when the error was created.
+func (s *MkErr) Category() Category {
+	return s.category
+}
+
+// WithCategory returns a copy of err with category c. An err that is not a
+// *MkErr is wrapped keeping its errno and message. It returns nil when err is nil.
+func WithCategory(err Error, c Category) Error {
+	if err == nil {
+		return nil
+	}
+	res, ok := err.(*MkErr)
+	if ok {
+		copied := *res
+		res = &copied
+	} else {
+		res = &MkErr{
+			errNo:     err.ErrNo(),
+			errMsg:    err.Error(),
+			errDetail: err.Error(),
+			cause:     err,
+		}
+	}
+	res.category = c
+	return res
+}
+
+// CategoryOf returns the category of the first error of err's chain having one:
+// an error with a Category method, an Error whose errno is registered with a
+// category, a context error or a net.Error timeout.
+func CategoryOf(err error) Category {
+	res := CategoryUnknown
+	walkChain(err, func(e error) bool {
+		if categorized, ok := e.(interface{ Category() Category }); ok {
+			if res = categorized.Category(); res != CategoryUnknown {
+				return true
+			}
+		}
+		if mkErr, ok := e.(Error); ok {
+			if code, ok := Lookup(mkErr.ErrNo()); ok && code.Category != CategoryUnknown {
+				res = code.Category
+				return true
+			}
+		}
+		switch {
+		case e == context.DeadlineExceeded:
+			res = CategoryTimeout
+		case e == context.Canceled:
+			res = CategoryCanceled
+		default:
+			if netErr, ok := e.(net.Error); ok && netErr.Timeout() {
+				res = CategoryTimeout
+			}
+		}
+		return res != CategoryUnknown
+	})
+	return res
+}
+
+// walkChain calls visit on err and on the errors it wraps, depth first, until
+// visit returns true.
+func walkChain(err error, visit func(err error) bool) bool {
+	if err == nil {
+		return false
+	}
+	if visit(err) {
+		return true
+	}
+	switch e := err.(type) {
+	case interface{ Unwrap() error }:
+		return walkChain(e.Unwrap(), visit)
+	case interface{ Unwrap() []error }:
+		for _, child := range e.Unwrap() {
+			if walkChain(child, visit) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func newCategoryError(c Category, errNo int64, errMsg string, errDetail string, st stack) Error {
+	return &MkErr{
+		errNo:     errNo,
+		errMsg:    errMsg,
+		errDetail: errDetail,
+		stack:     st,
+		category:  c,
+	}
+}
+
+func InvalidArgument(errNo int64, errMsg string, errDetail string) Error {
+	return newCategoryError(CategoryInvalidArgument, errNo, errMsg, errDetail, callers())
+}
+
+func NotFound(errNo int64, errMsg string, errDetail string) Error {
+	return newCategoryError(CategoryNotFound, errNo, errMsg, errDetail, callers())
+}
+
+func AlreadyExists(errNo int64, errMsg string, errDetail string) Error {
+	return newCategoryError(CategoryAlreadyExists, errNo, errMsg, errDetail, callers())
+}
+
+func PermissionDenied(errNo int64, errMsg string, errDetail string) Error {
+	return newCategoryError(CategoryPermissionDenied, errNo, errMsg, errDetail, callers())
+}
+
+func Unauthenticated(errNo int64, errMsg string, errDetail string) Error {
+	return newCategoryError(CategoryUnauthenticated, errNo, errMsg, errDetail, callers())
+}
+
+func ResourceExhausted(errNo int64, errMsg string, errDetail string) Error {
+	return newCategoryError(CategoryResourceExhausted, errNo, errMsg, errDetail, callers())
+}
+
+func FailedPrecondition(errNo int64, errMsg string, errDetail string) Error {
+	return newCategoryError(CategoryFailedPrecondition, errNo, errMsg, errDetail, callers())
+}
+
+func Canceled(errNo int64, errMsg string, errDetail string) Error {
+	return newCategoryError(CategoryCanceled, errNo, errMsg, errDetail, callers())
+}
+
+func Timeout(errNo int64, errMsg string, errDetail string) Error {
+	return newCategoryError(CategoryTimeout, errNo, errMsg, errDetail, callers())
+}
+
+func Unavailable(errNo int64, errMsg string, errDetail string) Error {
+	return newCategoryError(CategoryUnavailable, errNo, errMsg, errDetail, callers())
+}
+
+func Internal(errNo int64, errMsg string, errDetail string) Error {
+	return newCategoryError(CategoryInternal, errNo, errMsg, errDetail, callers())
+}
+
+func IsInvalidArgument(err error) bool {
+	return CategoryOf(err) == CategoryInvalidArgument
+}
+
+func IsNotFound(err error) bool {
+	return CategoryOf(err) == CategoryNotFound
+}
+
+func IsAlreadyExists(err error) bool {
+	return CategoryOf(err) == CategoryAlreadyExists
+}
+
+func IsPermissionDenied(err error) bool {
+	return CategoryOf(err) == CategoryPermissionDenied
+}
+
+func IsUnauthenticated(err error) bool {
+	return CategoryOf(err) == CategoryUnauthenticated
+}
+
+func IsResourceExhausted(err error) bool {
+	return CategoryOf(err) == CategoryResourceExhausted
+}
+
+func IsFailedPrecondition(err error) bool {
+	return CategoryOf(err) == CategoryFailedPrecondition
+}
+
+func IsCanceled(err error) bool {
+	return CategoryOf(err) == CategoryCanceled
+}
+
+func IsTimeout(err error) bool {
+	return CategoryOf(err) == CategoryTimeout
+}
+
+func IsUnavailable(err error) bool {
+	return CategoryOf(err) == CategoryUnavailable
+}
+
+func IsInternal(err error) bool {
+	return CategoryOf(err) == CategoryInternal
+}
@@ -0,0 +1,65 @@
+package mkerr
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"google.golang.org/grpc/codes"
+)
+
+func TestCategory(t *testing.T) {
+	err := NotFound(30404, "user not found", "uid 1")
+	wrapped := fmt.Errorf("handler: %w", Wrap(err, 30500, "query error"))
+	if !IsNotFound(wrapped) || IsTimeout(wrapped) || IsRetryable(wrapped) || CategoryOf(wrapped) != CategoryNotFound {
+		t.Fatalf("category error %+v", CategoryOf(wrapped))
+	}
+	if HTTPStatus(wrapped) != http.StatusNotFound || GRPCCode(wrapped) != codes.NotFound {
+		t.Fatalf("category mapping error %+v", wrapped)
+	}
+
+	b, _ := json.Marshal(err)
+	if string(b) != `{"errno":30404,"errmsg":"user not found","category":"not_found","detail":"uid 1"}` {
+		t.Fatalf("json.Marshal data error %s", b)
+	}
+	var res MkErr
+	if jsonErr := json.Unmarshal(b, &res); jsonErr != nil || !IsNotFound(&res) {
+		t.Fatalf("json.Unmarshal error %+v %+v", res, jsonErr)
+	}
+	if b, _ = json.Marshal(WithoutDetail(Wrap(err, 30500, "query error"))); string(b) != `{"errno":30500,"errmsg":"query error","category":"not_found"}` {
+		t.Fatalf("WithoutDetail data error %s", b)
+	}
+
+	if !IsUnavailable(WithCategory(NewError(1, "2", "3"), CategoryUnavailable)) || !IsRetryable(Unavailable(1, "2", "3")) {
+		t.Fatalf("WithCategory error")
+	}
+	if !IsTimeout(Wrap(context.DeadlineExceeded, 1, "2")) || !IsCanceled(fmt.Errorf("%w", context.Canceled)) || IsTimeout(errors.New("1")) {
+		t.Fatalf("context category error")
+	}
+	code := Register(Code{ErrNo: 30429, Message: "too many requests", Category: CategoryResourceExhausted})
+	t.Cleanup(func() {
+		unregister(30429)
+	})
+	if !IsResourceExhausted(NewError(30429, "2", "3")) || !IsRetryable(code.New("")) ||
+		HTTPStatus(code.New("")) != http.StatusTooManyRequests || GRPCCode(code.New("")) != codes.ResourceExhausted {
+		t.Fatalf("registered category error")
+	}
+
+	errs := NewMultiError(30400, "invalid argument")
+	errs.Add("name", InvalidArgument(30401, "is required", ""))
+	if !IsInvalidArgument(errs) {
+		t.Fatalf("MultiError category error")
+	}
+
+	var category Category
+	if jsonErr := json.Unmarshal([]byte(`"resource_exhausted"`), &category); jsonErr != nil || category != CategoryResourceExhausted {
+		t.Fatalf("category json error %+v", category)
+	}
+	if CategoryFromHTTPStatus(http.StatusServiceUnavailable) != CategoryUnavailable || CategoryFromHTTPStatus(http.StatusInternalServerError) != CategoryInternal ||
+		CategoryFromHTTPStatus(http.StatusOK) != CategoryUnknown {
+		t.Fatalf("CategoryFromHTTPStatus error")
+	}
+}
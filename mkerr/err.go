@@ -2,8 +2,6 @@ package mkerr
 
 import (
 	"fmt"
-	"runtime"
-	"strings"
 )
 
 type Error interface {
@@ -12,46 +10,92 @@ type Error interface {
 	ErrDetail() string
 }
 
-type mkerr struct {
+// MkErr is the Error implementation of this package. Use errors.As with a *MkErr
+// target to find it in an error chain.
+type MkErr struct {
 	errNo     int64
 	errMsg    string
 	errDetail string
+	cause     error
+	stack     stack
+	meta      map[string]interface{}
+	category  Category
 }
 
-func (s mkerr) ErrNo() int64 {
+func (s *MkErr) ErrNo() int64 {
 	return s.errNo
 }
 
-func (s mkerr) Error() string {
+func (s *MkErr) Error() string {
 	return s.errMsg
 }
 
-func (s mkerr) ErrDetail() string {
-	return s.errDetail
+// ErrDetail returns the detail followed by the stack, one frame per line.
+func (s *MkErr) ErrDetail() string {
+	return fmt.Sprintf("error: %s", s.errDetail) + s.stack.String()
+}
+
+// StackTrace returns the stack captured when the error was created, or nil when
+// stack capture was disabled.
+func (s *MkErr) StackTrace() []Frame {
+	return s.stack.frames()
+}
+
+// Unwrap returns the cause given to Wrap or Wrapf.
+func (s *MkErr) Unwrap() error {
+	return s.cause
+}
+
+// Is reports whether target is an Error with the same errno, so that
+// errors.Is(err, sentinel) matches by errno.
+func (s *MkErr) Is(target error) bool {
+	t, ok := target.(Error)
+	return ok && t.ErrNo() == s.errNo
 }
 
 func NewError(errNo int64, errMsg string, errDetail string) Error {
-	return mkerr{
+	return &MkErr{
 		errNo:     errNo,
 		errMsg:    errMsg,
-		errDetail: fmt.Sprintf("error: %s", errDetail) + getStackInfo(),
+		errDetail: errDetail,
+		stack:     callers(),
 	}
 }
 
-func getStackInfo() string {
-	stackInfo := ""
-	pcs := make([]uintptr, 128)
-	runtime.Callers(3, pcs)
-	for _, pc := range pcs {
-		if pc == 0 {
-			break
-		}
-		theFunc := runtime.FuncForPC(pc)
-		file, line := theFunc.FileLine(pc)
-		if strings.HasPrefix(file, "runtime") {
-			continue
-		}
-		stackInfo += fmt.Sprintf("\n    %s:%d", file, line-1)
+// NewErrorWithoutStack is NewError without stack capture, for expected errors
+// that are cheap to create and rarely logged.
+func NewErrorWithoutStack(errNo int64, errMsg string, errDetail string) Error {
+	return &MkErr{
+		errNo:     errNo,
+		errMsg:    errMsg,
+		errDetail: errDetail,
+	}
+}
+
+// Wrap returns an Error with errNo and errMsg whose cause is err, or nil when err is nil.
+func Wrap(err error, errNo int64, errMsg string) Error {
+	if err == nil {
+		return nil
+	}
+	return &MkErr{
+		errNo:     errNo,
+		errMsg:    errMsg,
+		errDetail: err.Error(),
+		cause:     err,
+		stack:     callers(),
+	}
+}
+
+// Wrapf is Wrap with a formatted errMsg.
+func Wrapf(err error, errNo int64, format string, args ...interface{}) Error {
+	if err == nil {
+		return nil
+	}
+	return &MkErr{
+		errNo:     errNo,
+		errMsg:    fmt.Sprintf(format, args...),
+		errDetail: err.Error(),
+		cause:     err,
+		stack:     callers(),
 	}
-	return stackInfo
 }
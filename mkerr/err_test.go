@@ -1,6 +1,13 @@
 package mkerr
 
-import "testing"
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"testing"
+)
 
 func TestError(t *testing.T) {
 	err := NewError(1, "2", "3")
@@ -10,3 +17,26 @@ func TestError(t *testing.T) {
 	var _ error = err
 	t.Fatalf("%+v", err.ErrDetail())
 }
+
+func TestWrap(t *testing.T) {
+	if Wrap(nil, 1, "2") != nil || Wrapf(nil, 1, "%d", 2) != nil {
+		t.Fatalf("Wrap nil error")
+	}
+	cause := &net.OpError{Op: "dial", Err: io.EOF}
+	err := Wrapf(cause, 1, "call %s error", "user")
+	if err.ErrNo() != 1 || err.Error() != "call user error" || !strings.HasPrefix(err.ErrDetail(), "error: dial: EOF") {
+		t.Fatalf("err data error %+v", err)
+	}
+	wrapped := fmt.Errorf("handler: %w", err)
+	if !errors.Is(wrapped, io.EOF) || !errors.Is(wrapped, NewError(1, "other", "")) || errors.Is(wrapped, NewError(2, "2", "")) {
+		t.Fatalf("errors.Is error %+v", wrapped)
+	}
+	var opErr *net.OpError
+	if !errors.As(wrapped, &opErr) || opErr != cause {
+		t.Fatalf("errors.As error %+v", wrapped)
+	}
+	var mkErr *MkErr
+	if !errors.As(wrapped, &mkErr) || mkErr.ErrNo() != 1 || mkErr.Unwrap() != cause {
+		t.Fatalf("errors.As error %+v", wrapped)
+	}
+}
@@ -0,0 +1,54 @@
+package mkerr
+
+import (
+	"fmt"
+	"io"
+	"sort"
+)
+
+// Format implements fmt.Formatter following pkg/errors: %s and %v print the
+// message, %q the quoted message, and %+v the errno, message, detail, metadata,
+// stack and the chain of causes. The detail is omitted when it is the message of
+// the cause, as set by Wrap:
+//
+//	[10404] user not found
+//	error: uid 1
+//	main.getUser
+//		/src/main.go:12
+//	caused by: sql: no rows in result set
+func (s *MkErr) Format(state fmt.State, verb rune) {
+	switch verb {
+	case 'v':
+		if state.Flag('+') {
+			fmt.Fprintf(state, "[%d] %s", s.errNo, s.errMsg)
+			if s.cause == nil || s.errDetail != s.cause.Error() {
+				fmt.Fprintf(state, "\nerror: %s", s.errDetail)
+			}
+			if len(s.meta) > 0 {
+				keys := make([]string, 0, len(s.meta))
+				for k := range s.meta {
+					keys = append(keys, k)
+				}
+				sort.Strings(keys)
+				io.WriteString(state, "\nmeta:")
+				for _, k := range keys {
+					fmt.Fprintf(state, " %s=%+v", k, s.meta[k])
+				}
+			}
+			for _, frame := range s.StackTrace() {
+				fmt.Fprintf(state, "\n%s\n\t%s:%d", frame.Function, frame.File, frame.Line)
+			}
+			if s.cause != nil {
+				fmt.Fprintf(state, "\ncaused by: %+v", s.cause)
+			}
+			return
+		}
+		io.WriteString(state, s.errMsg)
+	case 's':
+		io.WriteString(state, s.errMsg)
+	case 'q':
+		fmt.Fprintf(state, "%q", s.errMsg)
+	default:
+		fmt.Fprintf(state, "%%!%c(*mkerr.MkErr=%s)", verb, s.errMsg)
+	}
+}
@@ -0,0 +1,38 @@
+package mkerr
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestFormat(t *testing.T) {
+	cause := NewError(2, "db error", "connection reset")
+	err := Wrap(fmt.Errorf("query: %w", cause), 1, "user not found")
+	if s := fmt.Sprintf("%s|%v|%q", err, err, err); s != `user not found|user not found|"user not found"` {
+		t.Fatalf("Format data error %s", s)
+	}
+	s := fmt.Sprintf("%+v", err)
+	if !strings.HasPrefix(s, "[1] user not found\ngithub.com/zhongxuqi/mklibs/mkerr.TestFormat\n\t") {
+		t.Fatalf("Format data error %s", s)
+	}
+	if !strings.Contains(s, "\ncaused by: query: db error") {
+		t.Fatalf("Format data error %s", s)
+	}
+
+	err = Wrap(cause, 1, "user not found")
+	if s = fmt.Sprintf("%+v", err); !strings.Contains(s, "\ncaused by: [2] db error\nerror: connection reset\n") {
+		t.Fatalf("Format data error %s", s)
+	}
+	if s = fmt.Sprintf("%+v", NewErrorWithoutStack(1, "2", "3")); s != "[1] 2\nerror: 3" {
+		t.Fatalf("Format data error %s", s)
+	}
+	// detail 与 cause 相同时只打印一次
+	if s = fmt.Sprintf("%+v", Wrap(errors.New("eof"), 1, "2")); strings.Count(s, "eof") != 1 || strings.Contains(s, "\nerror: ") {
+		t.Fatalf("Format data error %s", s)
+	}
+	if s = fmt.Sprintf("%+v", &MkErr{errNo: 1, errMsg: "2", errDetail: "3", cause: errors.New("eof")}); s != "[1] 2\nerror: 3\ncaused by: eof" {
+		t.Fatalf("Format data error %s", s)
+	}
+}
@@ -0,0 +1,144 @@
+package mkerr
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+var (
+	catalogMutex  sync.RWMutex
+	catalogs      = make(map[string]map[int64]string)
+	defaultLocale = "en"
+)
+
+// RegisterMessages adds the user-facing messages of locale, keyed by errno, to
+// the message catalogs. A message may refer to the error metadata as {key}:
+//
+//	mkerr.RegisterMessages("zh-CN", map[int64]string{10400: "{field} 不能为空"})
+func RegisterMessages(locale string, messages map[int64]string) {
+	locale = normalizeLocale(locale)
+	catalogMutex.Lock()
+	defer catalogMutex.Unlock()
+	catalog, ok := catalogs[locale]
+	if !ok {
+		catalog = make(map[int64]string, len(messages))
+		catalogs[locale] = catalog
+	}
+	for errNo, message := range messages {
+		catalog[errNo] = message
+	}
+}
+
+// SetDefaultLocale sets the locale used when none of the accepted locales has a
+// message, "en" by default.
+func SetDefaultLocale(locale string) {
+	catalogMutex.Lock()
+	defer catalogMutex.Unlock()
+	defaultLocale = normalizeLocale(locale)
+}
+
+// Localize returns the user-facing message of err in the best locale of
+// acceptLanguage, an Accept-Language header value such as "zh-CN,zh;q=0.9,en;q=0.8".
+// It falls back to the default locale and then to the error message.
+func Localize(err error, acceptLanguage string) string {
+	var mkErr Error
+	if !errors.As(err, &mkErr) {
+		if err == nil {
+			return ""
+		}
+		return err.Error()
+	}
+	message, ok := LocalizedMessage(mkErr.ErrNo(), ParseAcceptLanguage(acceptLanguage)...)
+	if !ok {
+		return mkErr.Error()
+	}
+	for k, v := range Meta(err) {
+		message = strings.ReplaceAll(message, "{"+k+"}", fmt.Sprintf("%+v", v))
+	}
+	return message
+}
+
+// LocalizedMessage returns the catalog message of errNo for the first of locales
+// having one, matching a region-specific locale with its base language too, then
+// for the default locale.
+func LocalizedMessage(errNo int64, locales ...string) (string, bool) {
+	catalogMutex.RLock()
+	defer catalogMutex.RUnlock()
+	candidates := make([]string, 0, 2*len(locales)+1)
+	for _, locale := range locales {
+		locale = normalizeLocale(locale)
+		candidates = append(candidates, locale)
+		if idx := strings.Index(locale, "-"); idx > 0 {
+			candidates = append(candidates, locale[:idx])
+		}
+	}
+	candidates = append(candidates, defaultLocale)
+	for _, locale := range candidates {
+		if message, ok := catalogs[locale][errNo]; ok {
+			return message, true
+		}
+		// a base language also matches its regional catalogs, e.g. zh matches zh-cn
+		if strings.Contains(locale, "-") {
+			continue
+		}
+		regional := make([]string, 0)
+		for catalogLocale := range catalogs {
+			if strings.HasPrefix(catalogLocale, locale+"-") {
+				regional = append(regional, catalogLocale)
+			}
+		}
+		sort.Strings(regional)
+		for _, catalogLocale := range regional {
+			if message, ok := catalogs[catalogLocale][errNo]; ok {
+				return message, true
+			}
+		}
+	}
+	return "", false
+}
+
+// ParseAcceptLanguage returns the locales of an Accept-Language header value
+// sorted by decreasing quality, without the wildcard and the q=0 entries.
+func ParseAcceptLanguage(acceptLanguage string) []string {
+	type weightedLocale struct {
+		locale  string
+		quality float64
+	}
+	weighted := make([]weightedLocale, 0)
+	for _, part := range strings.Split(acceptLanguage, ",") {
+		items := strings.Split(strings.TrimSpace(part), ";")
+		locale := strings.TrimSpace(items[0])
+		if locale == "" || locale == "*" {
+			continue
+		}
+		quality := 1.0
+		for _, param := range items[1:] {
+			param = strings.TrimSpace(param)
+			if strings.HasPrefix(param, "q=") {
+				if q, err := strconv.ParseFloat(param[2:], 64); err == nil {
+					quality = q
+				}
+			}
+		}
+		if quality <= 0 {
+			continue
+		}
+		weighted = append(weighted, weightedLocale{locale: locale, quality: quality})
+	}
+	sort.SliceStable(weighted, func(i, j int) bool {
+		return weighted[i].quality > weighted[j].quality
+	})
+	res := make([]string, 0, len(weighted))
+	for _, item := range weighted {
+		res = append(res, item.locale)
+	}
+	return res
+}
+
+func normalizeLocale(locale string) string {
+	return strings.ToLower(strings.ReplaceAll(strings.TrimSpace(locale), "_", "-"))
+}
@@ -0,0 +1,37 @@
+package mkerr
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestLocalize(t *testing.T) {
+	if locales := ParseAcceptLanguage("fr-CH, fr;q=0.9, en;q=0.8, de;q=0.7, *;q=0.5, it;q=0"); !reflect.DeepEqual(locales, []string{"fr-CH", "fr", "en", "de"}) {
+		t.Fatalf("ParseAcceptLanguage data error %+v", locales)
+	}
+
+	RegisterMessages("en", map[int64]string{20400: "{field} is required", 20404: "not found"})
+	RegisterMessages("zh_CN", map[int64]string{20400: "{field} 不能为空"})
+	RegisterMessages("fr", map[int64]string{20404: "introuvable"})
+	err := WithMeta(NewError(20400, "invalid argument", "name is empty"), "field", "name")
+
+	if s := Localize(err, "zh-CN,zh;q=0.9,en;q=0.8"); s != "name 不能为空" {
+		t.Fatalf("Localize data error %s", s)
+	}
+	if s := Localize(err, "zh"); s != "name 不能为空" {
+		t.Fatalf("Localize data error %s", s)
+	}
+	if s := Localize(err, "de"); s != "name is required" {
+		t.Fatalf("Localize data error %s", s)
+	}
+	if s := Localize(NewError(20404, "not found", ""), "fr-CH"); s != "introuvable" {
+		t.Fatalf("Localize data error %s", s)
+	}
+	if s := Localize(NewError(20500, "internal error", ""), "fr"); s != "internal error" {
+		t.Fatalf("Localize data error %s", s)
+	}
+	if s := Localize(errors.New("eof"), "fr"); s != "eof" {
+		t.Fatalf("Localize data error %s", s)
+	}
+}
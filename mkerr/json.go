@@ -0,0 +1,97 @@
+package mkerr
+
+import (
+	"encoding/json"
+)
+
+// jsonError is the wire schema of an Error, the {"errno","errmsg"} body returned
+// by our APIs plus the optional category, detail and metadata.
+type jsonError struct {
+	ErrNo    int64                  `json:"errno"`
+	ErrMsg   string                 `json:"errmsg"`
+	Category Category               `json:"category,omitempty"`
+	Detail   string                 `json:"detail,omitempty"`
+	Meta     map[string]interface{} `json:"meta,omitempty"`
+	Errors   []jsonFieldError       `json:"errors,omitempty"`
+}
+
+// jsonFieldError is an entry of a MultiError, the error schema plus its field.
+type jsonFieldError struct {
+	Field string
+	Error json.RawMessage
+}
+
+func (s jsonFieldError) MarshalJSON() ([]byte, error) {
+	fields := make(map[string]json.RawMessage)
+	if err := json.Unmarshal(s.Error, &fields); err != nil {
+		return nil, err
+	}
+	field, err := json.Marshal(s.Field)
+	if err != nil {
+		return nil, err
+	}
+	fields["field"] = field
+	return json.Marshal(fields)
+}
+
+func (s *jsonFieldError) UnmarshalJSON(b []byte) error {
+	fields := make(map[string]json.RawMessage)
+	if err := json.Unmarshal(b, &fields); err != nil {
+		return err
+	}
+	if err := json.Unmarshal(fields["field"], &s.Field); err != nil {
+		return err
+	}
+	delete(fields, "field")
+	res, err := json.Marshal(fields)
+	if err != nil {
+		return err
+	}
+	s.Error = res
+	return nil
+}
+
+// MarshalJSON encodes the errno, message, category, detail and metadata. The stack and the cause are
+// local to the process and are not encoded.
+func (s *MkErr) MarshalJSON() ([]byte, error) {
+	return json.Marshal(jsonError{
+		ErrNo:    s.errNo,
+		ErrMsg:   s.errMsg,
+		Category: s.category,
+		Detail:   s.errDetail,
+		Meta:     s.meta,
+	})
+}
+
+func (s *MkErr) UnmarshalJSON(b []byte) error {
+	var res jsonError
+	if err := json.Unmarshal(b, &res); err != nil {
+		return err
+	}
+	*s = MkErr{
+		errNo:     res.ErrNo,
+		errMsg:    res.ErrMsg,
+		errDetail: res.Detail,
+		meta:      res.Meta,
+		category:  res.Category,
+	}
+	return nil
+}
+
+// WithoutDetail returns a copy of err holding only its errno, message and
+// category, without detail or metadata, safe to return to external callers. A
+// MultiError keeps its entries, each stripped the same way. It returns nil when
+// err is nil.
+func WithoutDetail(err Error) Error {
+	if err == nil {
+		return nil
+	}
+	if multiErr, ok := err.(*MultiError); ok {
+		return multiErr.withoutDetail()
+	}
+	return &MkErr{
+		errNo:    err.ErrNo(),
+		errMsg:   err.Error(),
+		category: CategoryOf(err),
+	}
+}
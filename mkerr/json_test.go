@@ -0,0 +1,40 @@
+package mkerr
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+)
+
+func TestJSON(t *testing.T) {
+	err := Wrap(errors.New("connection reset"), 1, "db error")
+	b, jsonErr := json.Marshal(err)
+	if jsonErr != nil {
+		t.Fatalf("json.Marshal error %+v", jsonErr)
+	} else if string(b) != `{"errno":1,"errmsg":"db error","detail":"connection reset"}` {
+		t.Fatalf("json.Marshal data error %s", b)
+	}
+	var res MkErr
+	if jsonErr = json.Unmarshal(b, &res); jsonErr != nil {
+		t.Fatalf("json.Unmarshal error %+v", jsonErr)
+	} else if res.ErrNo() != 1 || res.Error() != "db error" || res.ErrDetail() != "error: connection reset" || !errors.Is(&res, err) {
+		t.Fatalf("json.Unmarshal data error %+v", res)
+	}
+
+	b, _ = json.Marshal(map[string]interface{}{"error": WithoutDetail(err)})
+	if string(b) != `{"error":{"errno":1,"errmsg":"db error"}}` {
+		t.Fatalf("json.Marshal data error %s", b)
+	}
+
+	// same shape as the responses of our APIs
+	var apiRes struct {
+		ErrNo  int64  `json:"errno"`
+		ErrMsg string `json:"errmsg"`
+	}
+	if jsonErr = json.Unmarshal(b[len(`{"error":`):len(b)-1], &apiRes); jsonErr != nil || apiRes.ErrNo != 1 || apiRes.ErrMsg != "db error" {
+		t.Fatalf("json.Unmarshal data error %+v %+v", apiRes, jsonErr)
+	}
+	if WithoutDetail(nil) != nil {
+		t.Fatalf("WithoutDetail nil error")
+	}
+}
@@ -0,0 +1,57 @@
+package mkerr
+
+import (
+	"errors"
+)
+
+// WithMeta returns a copy of err with key set to value in its metadata. An err
+// that is not a *MkErr is wrapped keeping its errno and message. It returns nil
+// when err is nil.
+func WithMeta(err Error, key string, value interface{}) Error {
+	if err == nil {
+		return nil
+	}
+	res, ok := err.(*MkErr)
+	if ok {
+		copied := *res
+		res = &copied
+	} else {
+		res = &MkErr{
+			errNo:     err.ErrNo(),
+			errMsg:    err.Error(),
+			errDetail: err.Error(),
+			cause:     err,
+		}
+	}
+	meta := make(map[string]interface{}, len(res.meta)+1)
+	for k, v := range res.meta {
+		meta[k] = v
+	}
+	meta[key] = value
+	res.meta = meta
+	return res
+}
+
+// Meta returns the metadata attached to the error.
+func (s *MkErr) Meta() map[string]interface{} {
+	return s.meta
+}
+
+// Meta returns the metadata of every *MkErr in err's chain, the outer errors
+// overriding the inner ones.
+func Meta(err error) map[string]interface{} {
+	res := make(map[string]interface{})
+	chain := make([]*MkErr, 0)
+	for err != nil {
+		if mkErr, ok := err.(*MkErr); ok {
+			chain = append(chain, mkErr)
+		}
+		err = errors.Unwrap(err)
+	}
+	for i := len(chain) - 1; i >= 0; i-- {
+		for k, v := range chain[i].meta {
+			res[k] = v
+		}
+	}
+	return res
+}
@@ -0,0 +1,50 @@
+package mkerr
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestMeta(t *testing.T) {
+	base := NewError(10400, "invalid argument", "name is empty")
+	err := WithMeta(WithMeta(base, "field", "name"), "uid", 1)
+	if err.ErrNo() != 10400 || base.(*MkErr).Meta() != nil {
+		t.Fatalf("WithMeta data error %+v", err)
+	}
+	if meta := err.(*MkErr).Meta(); meta["field"] != "name" || meta["uid"] != 1 {
+		t.Fatalf("WithMeta data error %+v", meta)
+	}
+	wrapped := fmt.Errorf("handler: %w", Wrap(err, 1, "request error"))
+	if meta := Meta(WithMeta(Wrap(wrapped, 2, "2"), "uid", 2)); meta["field"] != "name" || meta["uid"] != 2 {
+		t.Fatalf("Meta data error %+v", meta)
+	}
+	if s := fmt.Sprintf("%+v", err); !strings.Contains(s, "\nmeta: field=name uid=1\n") {
+		t.Fatalf("Format data error %s", s)
+	}
+
+	b, _ := json.Marshal(err)
+	var res MkErr
+	if jsonErr := json.Unmarshal(b, &res); jsonErr != nil || res.Meta()["field"] != "name" {
+		t.Fatalf("json data error %s %+v", b, jsonErr)
+	}
+	if b, _ = json.Marshal(WithoutDetail(err)); string(b) != `{"errno":10400,"errmsg":"invalid argument"}` {
+		t.Fatalf("WithoutDetail data error %s", b)
+	}
+
+	other := WithMeta(otherError{}, "k", "v")
+	if other.ErrNo() != 1 || !errors.Is(other, otherError{}) || Meta(other)["k"] != "v" {
+		t.Fatalf("WithMeta data error %+v", other)
+	}
+	if WithMeta(nil, "k", "v") != nil {
+		t.Fatalf("WithMeta nil error")
+	}
+}
+
+type otherError struct{}
+
+func (otherError) ErrNo() int64      { return 1 }
+func (otherError) Error() string     { return "status error" }
+func (otherError) ErrDetail() string { return "" }
@@ -0,0 +1,189 @@
+package mkerr
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// FieldError is an entry of a MultiError: the error of one field, identified by
+// its path such as "items[0].name".
+type FieldError struct {
+	Field string
+	Err   Error
+}
+
+// MultiError collects the errors of a validation under a single errno.
+//
+//	errs := mkerr.NewMultiError(10400, "invalid argument")
+//	if req.Name == "" {
+//		errs.Add("name", mkerr.NewError(10401, "is required", ""))
+//	}
+//	return errs.ErrOrNil()
+type MultiError struct {
+	errNo  int64
+	errMsg string
+	errs   []FieldError
+}
+
+func NewMultiError(errNo int64, errMsg string) *MultiError {
+	return &MultiError{
+		errNo:  errNo,
+		errMsg: errMsg,
+	}
+}
+
+// Add appends the error of field, a nil err is ignored.
+func (s *MultiError) Add(field string, err Error) {
+	if err == nil {
+		return
+	}
+	s.errs = append(s.errs, FieldError{
+		Field: field,
+		Err:   err,
+	})
+}
+
+// Errors returns the collected entries.
+func (s *MultiError) Errors() []FieldError {
+	return s.errs
+}
+
+func (s *MultiError) Len() int {
+	return len(s.errs)
+}
+
+// ErrOrNil returns nil when no error was added, and s otherwise.
+func (s *MultiError) ErrOrNil() Error {
+	if s == nil || len(s.errs) == 0 {
+		return nil
+	}
+	return s
+}
+
+func (s *MultiError) ErrNo() int64 {
+	return s.errNo
+}
+
+// Error returns the message followed by the message of every entry.
+func (s *MultiError) Error() string {
+	msgs := make([]string, 0, len(s.errs))
+	for _, fieldErr := range s.errs {
+		msgs = append(msgs, fmt.Sprintf("%s: %s", fieldErr.Field, fieldErr.Err.Error()))
+	}
+	if len(msgs) == 0 {
+		return s.errMsg
+	}
+	return fmt.Sprintf("%s: %s", s.errMsg, strings.Join(msgs, "; "))
+}
+
+// ErrDetail returns the detail of every entry, one entry per paragraph.
+func (s *MultiError) ErrDetail() string {
+	details := make([]string, 0, len(s.errs))
+	for _, fieldErr := range s.errs {
+		details = append(details, fmt.Sprintf("%s: [%d] %s\n%s", fieldErr.Field, fieldErr.Err.ErrNo(), fieldErr.Err.Error(), fieldErr.Err.ErrDetail()))
+	}
+	return strings.Join(details, "\n")
+}
+
+// Unwrap returns the entries, so errors.Is and errors.As look into each of them.
+func (s *MultiError) Unwrap() []error {
+	res := make([]error, 0, len(s.errs))
+	for _, fieldErr := range s.errs {
+		res = append(res, fieldErr.Err)
+	}
+	return res
+}
+
+// Is reports whether target is an Error with the same errno.
+func (s *MultiError) Is(target error) bool {
+	t, ok := target.(Error)
+	return ok && t.ErrNo() == s.errNo
+}
+
+// Format prints every entry with the verb, see MkErr.Format.
+func (s *MultiError) Format(state fmt.State, verb rune) {
+	switch {
+	case verb == 'v' && state.Flag('+'):
+		fmt.Fprintf(state, "[%d] %s", s.errNo, s.errMsg)
+		for _, fieldErr := range s.errs {
+			fmt.Fprintf(state, "\n%s: %+v", fieldErr.Field, fieldErr.Err)
+		}
+	case verb == 'q':
+		fmt.Fprintf(state, "%q", s.Error())
+	default:
+		io.WriteString(state, s.Error())
+	}
+}
+
+// MarshalJSON encodes the errno and message with every entry in "errors".
+func (s *MultiError) MarshalJSON() ([]byte, error) {
+	res := jsonError{
+		ErrNo:  s.errNo,
+		ErrMsg: s.errMsg,
+		Errors: make([]jsonFieldError, 0, len(s.errs)),
+	}
+	for _, fieldErr := range s.errs {
+		b, err := json.Marshal(fieldErr.Err)
+		if err != nil {
+			return nil, err
+		}
+		res.Errors = append(res.Errors, jsonFieldError{
+			Field: fieldErr.Field,
+			Error: b,
+		})
+	}
+	return json.Marshal(res)
+}
+
+func (s *MultiError) UnmarshalJSON(b []byte) error {
+	var res jsonError
+	if err := json.Unmarshal(b, &res); err != nil {
+		return err
+	}
+	*s = MultiError{
+		errNo:  res.ErrNo,
+		errMsg: res.ErrMsg,
+	}
+	for _, fieldErr := range res.Errors {
+		entry, err := unmarshalEntry(fieldErr.Error)
+		if err != nil {
+			return err
+		}
+		s.Add(fieldErr.Field, entry)
+	}
+	return nil
+}
+
+// unmarshalEntry decodes an entry of a MultiError, a MultiError itself when it
+// has "errors".
+func unmarshalEntry(b []byte) (Error, error) {
+	var probe struct {
+		Errors json.RawMessage `json:"errors"`
+	}
+	if err := json.Unmarshal(b, &probe); err != nil {
+		return nil, err
+	}
+	if len(probe.Errors) > 0 {
+		entry := &MultiError{}
+		if err := json.Unmarshal(b, entry); err != nil {
+			return nil, err
+		}
+		return entry, nil
+	}
+	entry := &MkErr{}
+	if err := json.Unmarshal(b, entry); err != nil {
+		return nil, err
+	}
+	return entry, nil
+}
+
+// withoutDetail returns a copy of s whose entries are stripped by WithoutDetail.
+func (s *MultiError) withoutDetail() *MultiError {
+	res := NewMultiError(s.errNo, s.errMsg)
+	for _, fieldErr := range s.errs {
+		res.Add(fieldErr.Field, WithoutDetail(fieldErr.Err))
+	}
+	return res
+}
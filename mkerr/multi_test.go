@@ -0,0 +1,82 @@
+package mkerr
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestMultiError(t *testing.T) {
+	errs := NewMultiError(10400, "invalid argument")
+	if errs.ErrOrNil() != nil {
+		t.Fatalf("ErrOrNil should be nil")
+	}
+	errs.Add("name", NewError(10401, "is required", "name is empty"))
+	errs.Add("age", nil)
+	errs.Add("items[0].count", WithMeta(NewError(10402, "out of range", "count -1"), "min", 0))
+	err := errs.ErrOrNil()
+	if err == nil || errs.Len() != 2 || err.ErrNo() != 10400 {
+		t.Fatalf("MultiError data error %+v", errs)
+	}
+	if err.Error() != "invalid argument: name: is required; items[0].count: out of range" {
+		t.Fatalf("Error data error %s", err.Error())
+	}
+	if detail := err.ErrDetail(); !strings.HasPrefix(detail, "name: [10401] is required\nerror: name is empty\n") ||
+		!strings.Contains(detail, "\nitems[0].count: [10402] out of range\nerror: count -1\n") {
+		t.Fatalf("ErrDetail data error %s", detail)
+	}
+
+	wrapped := fmt.Errorf("handler: %w", err)
+	var mkErr *MkErr
+	if !errors.Is(wrapped, NewError(10402, "", "")) || !errors.Is(wrapped, NewError(10400, "", "")) ||
+		!errors.As(wrapped, &mkErr) || mkErr.ErrNo() != 10401 {
+		t.Fatalf("errors.Is/As error %+v", wrapped)
+	}
+	if s := fmt.Sprintf("%+v", err); !strings.HasPrefix(s, "[10400] invalid argument\nname: [10401] is required\n") {
+		t.Fatalf("Format data error %s", s)
+	}
+
+	b, jsonErr := json.Marshal(err)
+	if jsonErr != nil {
+		t.Fatalf("json.Marshal error %+v", jsonErr)
+	} else if string(b) != `{"errno":10400,"errmsg":"invalid argument","errors":[`+
+		`{"detail":"name is empty","errmsg":"is required","errno":10401,"field":"name"},`+
+		`{"detail":"count -1","errmsg":"out of range","errno":10402,"field":"items[0].count","meta":{"min":0}}]}` {
+		t.Fatalf("json.Marshal data error %s", b)
+	}
+	var res MultiError
+	if jsonErr = json.Unmarshal(b, &res); jsonErr != nil {
+		t.Fatalf("json.Unmarshal error %+v", jsonErr)
+	} else if res.Error() != err.Error() || res.Errors()[1].Field != "items[0].count" || res.Errors()[1].Err.ErrNo() != 10402 {
+		t.Fatalf("json.Unmarshal data error %+v", res)
+	}
+
+	// 嵌套的 MultiError 序列化后可以还原
+	nested := NewMultiError(10400, "invalid argument")
+	nested.Add("items[1]", errs)
+	nested.Add("name", NewError(10401, "is required", "name is empty"))
+	if b, jsonErr = json.Marshal(nested); jsonErr != nil {
+		t.Fatalf("json.Marshal error %+v", jsonErr)
+	}
+	res = MultiError{}
+	if jsonErr = json.Unmarshal(b, &res); jsonErr != nil {
+		t.Fatalf("json.Unmarshal error %+v", jsonErr)
+	}
+	if resB, _ := json.Marshal(&res); string(resB) != string(b) || res.Error() != nested.Error() {
+		t.Fatalf("json.Unmarshal data error %s", resB)
+	}
+	if inner, ok := res.Errors()[0].Err.(*MultiError); !ok || inner.Len() != 2 {
+		t.Fatalf("json.Unmarshal nested error %+v", res.Errors()[0].Err)
+	}
+
+	// WithoutDetail 保留每个条目, 去掉其 detail 和 meta
+	if b, _ = json.Marshal(WithoutDetail(nested)); string(b) != `{"errno":10400,"errmsg":"invalid argument","errors":[`+
+		`{"errmsg":"invalid argument","errno":10400,"errors":[`+
+		`{"errmsg":"is required","errno":10401,"field":"name"},`+
+		`{"errmsg":"out of range","errno":10402,"field":"items[0].count"}],"field":"items[1]"},`+
+		`{"errmsg":"is required","errno":10401,"field":"name"}]}` {
+		t.Fatalf("WithoutDetail data error %s", b)
+	}
+}
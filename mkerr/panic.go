@@ -0,0 +1,25 @@
+package mkerr
+
+import (
+	"fmt"
+)
+
+// ErrNoPanic is the errno of the errors converted from panics.
+const ErrNoPanic int64 = -100
+
+// FromPanic converts a recovered panic value into an internal Error holding the
+// stack of the panic. Call it from the deferred function calling recover. A
+// panic value that is an error becomes the cause.
+func FromPanic(value interface{}) Error {
+	res := &MkErr{
+		errNo:     ErrNoPanic,
+		errMsg:    fmt.Sprintf("panic: %v", value),
+		errDetail: fmt.Sprintf("%+v", value),
+		stack:     callers(),
+		category:  CategoryInternal,
+	}
+	if err, ok := value.(error); ok {
+		res.cause = err
+	}
+	return res
+}
@@ -0,0 +1,139 @@
+package mkerr
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+
+	"google.golang.org/grpc/codes"
+)
+
+// Code declares an errno once, with its default message and how it maps to HTTP
+// and gRPC statuses. Declare codes as package variables with Register:
+//
+//	var ErrUserNotFound = mkerr.Register(mkerr.Code{
+//		ErrNo: 10404, Message: "user not found", HTTPStatus: http.StatusNotFound, GRPCCode: codes.NotFound,
+//	})
+type Code struct {
+	ErrNo      int64
+	Message    string
+	HTTPStatus int        // the status of Category when zero
+	GRPCCode   codes.Code // the code of Category when zero, codes.OK is only used for errno 0
+	Retryable  bool
+	NoStack    bool // errors of this code do not capture the stack
+	Category   Category
+}
+
+var (
+	registryMutex sync.RWMutex
+	registry      = make(map[int64]Code)
+)
+
+// Register adds code to the registry and returns it with defaults filled in. It
+// panics when the errno is already registered, so duplicates fail at init.
+func Register(code Code) Code {
+	if code.HTTPStatus == 0 {
+		code.HTTPStatus = code.Category.HTTPStatus()
+	}
+	if code.GRPCCode == codes.OK && code.ErrNo != 0 {
+		code.GRPCCode = code.Category.GRPCCode()
+	}
+	registryMutex.Lock()
+	defer registryMutex.Unlock()
+	if registered, ok := registry[code.ErrNo]; ok {
+		panic(fmt.Sprintf("mkerr: errno %d already registered as %q", code.ErrNo, registered.Message))
+	}
+	registry[code.ErrNo] = code
+	return code
+}
+
+// unregister removes errNo from the registry, for tests
+func unregister(errNo int64) {
+	registryMutex.Lock()
+	defer registryMutex.Unlock()
+	delete(registry, errNo)
+}
+
+// Lookup returns the registered code of errNo.
+func Lookup(errNo int64) (Code, bool) {
+	registryMutex.RLock()
+	defer registryMutex.RUnlock()
+	code, ok := registry[errNo]
+	return code, ok
+}
+
+// New returns an Error with the code's errno and default message.
+func (c Code) New(errDetail string) Error {
+	res := &MkErr{
+		errNo:     c.ErrNo,
+		errMsg:    c.Message,
+		errDetail: errDetail,
+		category:  c.Category,
+	}
+	if !c.NoStack {
+		res.stack = callers()
+	}
+	return res
+}
+
+// Wrap returns an Error with the code's errno and default message whose cause
+// is err, or nil when err is nil.
+func (c Code) Wrap(err error) Error {
+	if err == nil {
+		return nil
+	}
+	res := &MkErr{
+		errNo:     c.ErrNo,
+		errMsg:    c.Message,
+		errDetail: err.Error(),
+		cause:     err,
+		category:  c.Category,
+	}
+	if !c.NoStack {
+		res.stack = callers()
+	}
+	return res
+}
+
+// CodeOf returns the registered code of the first Error in err's chain.
+func CodeOf(err error) (Code, bool) {
+	var mkErr Error
+	if !errors.As(err, &mkErr) {
+		return Code{}, false
+	}
+	return Lookup(mkErr.ErrNo())
+}
+
+// HTTPStatus returns the HTTP status of err: http.StatusOK for nil, the status
+// of its registered code, else the status of its category.
+func HTTPStatus(err error) int {
+	if err == nil {
+		return http.StatusOK
+	}
+	if code, ok := CodeOf(err); ok {
+		return code.HTTPStatus
+	}
+	return CategoryOf(err).HTTPStatus()
+}
+
+// GRPCCode returns the gRPC code of err: codes.OK for nil, the code of its
+// registered code, else the code of its category.
+func GRPCCode(err error) codes.Code {
+	if err == nil {
+		return codes.OK
+	}
+	if code, ok := CodeOf(err); ok {
+		return code.GRPCCode
+	}
+	return CategoryOf(err).GRPCCode()
+}
+
+// IsRetryable reports whether err has a registered code marked retryable or a
+// retryable category: unavailable, timeout or resource exhausted.
+func IsRetryable(err error) bool {
+	if code, ok := CodeOf(err); ok && code.Retryable {
+		return true
+	}
+	return CategoryOf(err).Retryable()
+}
@@ -0,0 +1,47 @@
+package mkerr
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"google.golang.org/grpc/codes"
+)
+
+func TestRegistry(t *testing.T) {
+	// 注册表是全局的, 测试结束后注销以支持 -count
+	t.Cleanup(func() {
+		unregister(10404)
+		unregister(10503)
+	})
+	notFound := Register(Code{ErrNo: 10404, Message: "user not found", HTTPStatus: http.StatusNotFound, GRPCCode: codes.NotFound})
+	unavailable := Register(Code{ErrNo: 10503, Message: "user service unavailable", Retryable: true})
+	if unavailable.HTTPStatus != http.StatusInternalServerError || unavailable.GRPCCode != codes.Unknown {
+		t.Fatalf("Register default error %+v", unavailable)
+	}
+	func() {
+		defer func() {
+			if recover() == nil {
+				t.Fatalf("Register duplicate should panic")
+			}
+		}()
+		Register(Code{ErrNo: 10404, Message: "duplicate"})
+	}()
+
+	err := fmt.Errorf("handler: %w", notFound.New("uid 1"))
+	if code, ok := CodeOf(err); !ok || code.Message != "user not found" {
+		t.Fatalf("CodeOf error %+v", code)
+	}
+	if HTTPStatus(err) != http.StatusNotFound || GRPCCode(err) != codes.NotFound || IsRetryable(err) {
+		t.Fatalf("code mapping error %+v", err)
+	}
+	err = unavailable.Wrap(errors.New("connection refused"))
+	if err.(Error).ErrNo() != 10503 || err.Error() != "user service unavailable" || !IsRetryable(err) {
+		t.Fatalf("code mapping error %+v", err)
+	}
+	if HTTPStatus(nil) != http.StatusOK || GRPCCode(nil) != codes.OK ||
+		HTTPStatus(NewError(1, "2", "3")) != http.StatusInternalServerError || GRPCCode(errors.New("1")) != codes.Unknown {
+		t.Fatalf("code mapping error")
+	}
+}
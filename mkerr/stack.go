@@ -0,0 +1,75 @@
+package mkerr
+
+import (
+	"fmt"
+	"runtime"
+	"strings"
+	"sync/atomic"
+)
+
+const maxStackDepth = 128
+
+// Frame is a single frame of the stack captured when an error is created.
+type Frame struct {
+	Function string
+	File     string
+	Line     int
+}
+
+// stack holds the program counters of the error creation; they are resolved into
+// frames only when the stack is read.
+type stack []uintptr
+
+var stackCaptureDisabled int32
+
+// SetStackCapture enables or disables stack capture for all errors created
+// afterwards. It is enabled by default.
+func SetStackCapture(enable bool) {
+	if enable {
+		atomic.StoreInt32(&stackCaptureDisabled, 0)
+	} else {
+		atomic.StoreInt32(&stackCaptureDisabled, 1)
+	}
+}
+
+// callers captures the stack of the caller of the function calling callers.
+func callers() stack {
+	if atomic.LoadInt32(&stackCaptureDisabled) != 0 {
+		return nil
+	}
+	var pcs [maxStackDepth]uintptr
+	n := runtime.Callers(3, pcs[:])
+	res := make(stack, n)
+	copy(res, pcs[:n])
+	return res
+}
+
+func (s stack) frames() []Frame {
+	if len(s) == 0 {
+		return nil
+	}
+	res := make([]Frame, 0, len(s))
+	frames := runtime.CallersFrames(s)
+	for {
+		frame, more := frames.Next()
+		if !strings.HasPrefix(frame.Function, "runtime.") {
+			res = append(res, Frame{
+				Function: frame.Function,
+				File:     frame.File,
+				Line:     frame.Line,
+			})
+		}
+		if !more {
+			break
+		}
+	}
+	return res
+}
+
+func (s stack) String() string {
+	res := ""
+	for _, frame := range s.frames() {
+		res += fmt.Sprintf("\n    %s:%d", frame.File, frame.Line)
+	}
+	return res
+}
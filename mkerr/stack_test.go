@@ -0,0 +1,43 @@
+package mkerr
+
+import (
+	"runtime"
+	"strings"
+	"testing"
+)
+
+func TestStackTrace(t *testing.T) {
+	_, file, line, _ := runtime.Caller(0)
+	err := NewError(1, "2", "3").(*MkErr)
+	frames := err.StackTrace()
+	if len(frames) == 0 || frames[0].File != file || frames[0].Line != line+1 ||
+		!strings.HasSuffix(frames[0].Function, "mkerr.TestStackTrace") {
+		t.Fatalf("StackTrace data error %+v", frames)
+	}
+	for _, frame := range frames {
+		if strings.HasPrefix(frame.Function, "runtime.") {
+			t.Fatalf("StackTrace should skip runtime frames %+v", frames)
+		}
+	}
+	if detail := err.ErrDetail(); !strings.HasPrefix(detail, "error: 3\n    "+frames[0].File) {
+		t.Fatalf("ErrDetail data error %+v", detail)
+	}
+
+	if err := NewErrorWithoutStack(1, "2", "3"); err.(*MkErr).StackTrace() != nil || err.ErrDetail() != "error: 3" {
+		t.Fatalf("NewErrorWithoutStack data error %+v", err.ErrDetail())
+	}
+	if err := (Code{ErrNo: 1, NoStack: true}).New("3"); err.(*MkErr).StackTrace() != nil {
+		t.Fatalf("Code.New data error %+v", err.ErrDetail())
+	}
+	SetStackCapture(false)
+	defer SetStackCapture(true)
+	if err := Wrap(err, 1, "2"); err.(*MkErr).StackTrace() != nil {
+		t.Fatalf("SetStackCapture error %+v", err.ErrDetail())
+	}
+}
+
+func BenchmarkNewError(b *testing.B) {
+	for i := 0; i < b.N; i++ {
+		NewError(1, "2", "3")
+	}
+}
@@ -0,0 +1,209 @@
+package mkhttpclient
+
+import (
+	"hash/fnv"
+	"math/rand"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Endpoint is a replica a client balances its requests over
+type Endpoint struct {
+	Host string // scheme and host, like http://10.0.0.1:8080
+
+	outstanding  int64
+	mutex        sync.Mutex
+	failures     int
+	ejectedUntil time.Time
+}
+
+// Outstanding returns the number of requests in flight to the endpoint
+func (s *Endpoint) Outstanding() int64 {
+	return atomic.LoadInt64(&s.outstanding)
+}
+
+// healthy reports whether the endpoint is not ejected at now
+func (s *Endpoint) healthy(now time.Time) bool {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return !now.Before(s.ejectedUntil)
+}
+
+// record counts the result of a request to the endpoint, ejecting it for
+// ejectDuration after ejectFailures consecutive failures
+func (s *Endpoint) record(attempt RetryAttempt, ejectFailures int, ejectDuration time.Duration) {
+	failed, counted := attemptFailed(attempt)
+	if !counted {
+		return
+	}
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if !failed {
+		s.failures = 0
+		return
+	}
+	s.failures++
+	if ejectFailures > 0 && s.failures >= ejectFailures {
+		s.failures = 0
+		s.ejectedUntil = time.Now().Add(ejectDuration)
+	}
+}
+
+// Balancer picks the endpoint of a request among the candidates, key is the
+// balance key of the call, empty unless given with WithBalanceKey
+type Balancer interface {
+	Pick(endpoints []*Endpoint, key string) *Endpoint
+}
+
+// BalancerFunc ...
+type BalancerFunc func(endpoints []*Endpoint, key string) *Endpoint
+
+// Pick ...
+func (f BalancerFunc) Pick(endpoints []*Endpoint, key string) *Endpoint {
+	return f(endpoints, key)
+}
+
+type roundRobinBalancer struct {
+	next uint64
+}
+
+// NewRoundRobinBalancer returns a Balancer picking the endpoints in turn
+func NewRoundRobinBalancer() Balancer {
+	return &roundRobinBalancer{}
+}
+
+// Pick ...
+func (s *roundRobinBalancer) Pick(endpoints []*Endpoint, key string) *Endpoint {
+	if len(endpoints) == 0 {
+		return nil
+	}
+	return endpoints[(atomic.AddUint64(&s.next, 1)-1)%uint64(len(endpoints))]
+}
+
+// NewRandomBalancer returns a Balancer picking a random endpoint
+func NewRandomBalancer() Balancer {
+	return BalancerFunc(pickRandom)
+}
+
+func pickRandom(endpoints []*Endpoint, key string) *Endpoint {
+	if len(endpoints) == 0 {
+		return nil
+	}
+	return endpoints[rand.Intn(len(endpoints))]
+}
+
+// NewLeastOutstandingBalancer returns a Balancer picking the endpoint with the
+// fewest requests in flight, ties are broken randomly
+func NewLeastOutstandingBalancer() Balancer {
+	return BalancerFunc(func(endpoints []*Endpoint, key string) *Endpoint {
+		if len(endpoints) == 0 {
+			return nil
+		}
+		offset := rand.Intn(len(endpoints))
+		var res *Endpoint
+		for i := range endpoints {
+			endpoint := endpoints[(i+offset)%len(endpoints)]
+			if res == nil || endpoint.Outstanding() < res.Outstanding() {
+				res = endpoint
+			}
+		}
+		return res
+	})
+}
+
+// NewConsistentHashBalancer returns a Balancer sending the requests of a key to
+// the same endpoint, using rendezvous hashing so that only the keys of an
+// added or removed endpoint move. Requests without key go to a random endpoint.
+func NewConsistentHashBalancer() Balancer {
+	return BalancerFunc(func(endpoints []*Endpoint, key string) *Endpoint {
+		if key == "" {
+			return pickRandom(endpoints, key)
+		}
+		var res *Endpoint
+		var maxScore uint64
+		for _, endpoint := range endpoints {
+			h := fnv.New64a()
+			h.Write([]byte(endpoint.Host))
+			h.Write([]byte{0})
+			h.Write([]byte(key))
+			if score := h.Sum64(); res == nil || score > maxScore {
+				res = endpoint
+				maxScore = score
+			}
+		}
+		return res
+	})
+}
+
+// endpointSet is the set of endpoints of a client, updated at runtime by resolvers
+type endpointSet struct {
+	mutex     sync.RWMutex
+	endpoints []*Endpoint
+}
+
+func newEndpointSet(hosts []string) *endpointSet {
+	res := &endpointSet{}
+	res.update(hosts)
+	return res
+}
+
+// update replaces the endpoints with hosts, keeping the state of the endpoints
+// still present
+func (s *endpointSet) update(hosts []string) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	existing := make(map[string]*Endpoint, len(s.endpoints))
+	for _, endpoint := range s.endpoints {
+		existing[endpoint.Host] = endpoint
+	}
+	endpoints := make([]*Endpoint, 0, len(hosts))
+	for _, host := range hosts {
+		endpoint, ok := existing[host]
+		if !ok {
+			endpoint = &Endpoint{Host: host}
+		}
+		delete(existing, host)
+		endpoints = append(endpoints, endpoint)
+	}
+	s.endpoints = endpoints
+}
+
+// hosts ...
+func (s *endpointSet) hosts() []string {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	res := make([]string, 0, len(s.endpoints))
+	for _, endpoint := range s.endpoints {
+		res = append(res, endpoint.Host)
+	}
+	return res
+}
+
+// pick picks an endpoint with balancer, preferring the healthy endpoints not
+// tried yet by the call. When every endpoint is ejected they are all candidates.
+// It returns nil when the set is empty.
+func (s *endpointSet) pick(balancer Balancer, key string, tried map[*Endpoint]bool) *Endpoint {
+	s.mutex.RLock()
+	endpoints := s.endpoints
+	s.mutex.RUnlock()
+
+	now := time.Now()
+	healthy := make([]*Endpoint, 0, len(endpoints))
+	untried := make([]*Endpoint, 0, len(endpoints))
+	for _, endpoint := range endpoints {
+		if endpoint.healthy(now) {
+			healthy = append(healthy, endpoint)
+			if !tried[endpoint] {
+				untried = append(untried, endpoint)
+			}
+		}
+	}
+	switch {
+	case len(untried) > 0:
+		return balancer.Pick(untried, key)
+	case len(healthy) > 0:
+		return balancer.Pick(healthy, key)
+	}
+	return balancer.Pick(endpoints, key)
+}
@@ -0,0 +1,123 @@
+package mkhttpclient
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestBalancer(t *testing.T) {
+	set := newEndpointSet([]string{"http://a", "http://b", "http://c"})
+	endpoints := set.endpoints
+
+	roundRobin := NewRoundRobinBalancer()
+	for i := 0; i < 6; i++ {
+		if endpoint := roundRobin.Pick(endpoints, ""); endpoint != endpoints[i%3] {
+			t.Fatalf("round robin pick %d %s", i, endpoint.Host)
+		}
+	}
+
+	atomic.StoreInt64(&endpoints[0].outstanding, 2)
+	atomic.StoreInt64(&endpoints[2].outstanding, 1)
+	if endpoint := NewLeastOutstandingBalancer().Pick(endpoints, ""); endpoint != endpoints[1] {
+		t.Fatalf("least outstanding pick %s", endpoint.Host)
+	}
+
+	consistentHash := NewConsistentHashBalancer()
+	picked := make(map[string]*Endpoint)
+	for i := 0; i < 100; i++ {
+		key := fmt.Sprintf("user%d", i)
+		picked[key] = consistentHash.Pick(endpoints, key)
+		if consistentHash.Pick(endpoints, key) != picked[key] {
+			t.Fatalf("consistent hash pick of %s changed", key)
+		}
+	}
+	// 删除一个节点只影响该节点上的key
+	for key, endpoint := range picked {
+		if endpoint != endpoints[2] && consistentHash.Pick(endpoints[:2], key) != endpoint {
+			t.Fatalf("consistent hash moved %s", key)
+		}
+	}
+
+	if NewRandomBalancer().Pick(nil, "") != nil {
+		t.Fatalf("random pick of no endpoints")
+	}
+}
+
+func TestEndpointSet(t *testing.T) {
+	set := newEndpointSet([]string{"http://a", "http://b"})
+	a := set.endpoints[0]
+	failure := RetryAttempt{StatusCode: http.StatusBadGateway}
+	a.record(failure, 2, time.Minute)
+	a.record(RetryAttempt{StatusCode: http.StatusOK}, 2, time.Minute)
+	a.record(failure, 2, time.Minute)
+	if !a.healthy(time.Now()) {
+		t.Fatalf("endpoint ejected without consecutive failures")
+	}
+	a.record(failure, 2, time.Minute)
+	if a.healthy(time.Now()) {
+		t.Fatalf("endpoint not ejected")
+	}
+
+	balancer := NewRoundRobinBalancer()
+	for i := 0; i < 4; i++ {
+		if endpoint := set.pick(balancer, "", nil); endpoint.Host != "http://b" {
+			t.Fatalf("picked ejected endpoint")
+		}
+	}
+	// 重试时优先选择其他节点, 全部不健康时选择所有节点
+	if endpoint := set.pick(balancer, "", map[*Endpoint]bool{set.endpoints[1]: true}); endpoint.Host != "http://b" {
+		t.Fatalf("picked ejected endpoint")
+	}
+	set.endpoints[1].record(failure, 1, time.Minute)
+	if endpoint := set.pick(balancer, "", nil); endpoint == nil {
+		t.Fatalf("no endpoint picked")
+	}
+
+	// 更新保留已有节点的状态
+	set.update([]string{"http://c", "http://a"})
+	if hosts := set.hosts(); len(hosts) != 2 || hosts[0] != "http://c" || set.endpoints[1] != a {
+		t.Fatalf("set.update error %+v", hosts)
+	}
+	set.update(nil)
+	if set.pick(balancer, "", nil) != nil {
+		t.Fatalf("picked endpoint of empty set")
+	}
+}
+
+func TestHttpBalance(t *testing.T) {
+	var badCount, goodCount int32
+	bad := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&badCount, 1)
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer bad.Close()
+	good := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&goodCount, 1)
+		w.Write([]byte(`{"errno":0,"errmsg":"success"}`))
+	}))
+	defer good.Close()
+
+	client, err := NewMultiHostHTTPClient([]string{bad.URL, good.URL}, WithRetryTimes(1), WithRetryHttpError(true), WithEjection(2, time.Minute))
+	if err != nil {
+		t.Fatalf("NewMultiHostHTTPClient error %+v", err)
+	}
+	defer client.(*httpClient).client.CloseIdleConnections()
+	var res testRes
+	for i := 0; i < 6; i++ {
+		if err := client.Get(context.TODO(), "/", nil, &res); err != nil {
+			t.Fatalf("client.Get error %+v", err)
+		}
+	}
+	if atomic.LoadInt32(&badCount) != 2 || atomic.LoadInt32(&goodCount) != 6 {
+		t.Fatalf("bad called %d times, good called %d times", badCount, goodCount)
+	}
+
+	if _, err := NewMultiHostHTTPClient(nil); err == nil {
+		t.Fatalf("NewMultiHostHTTPClient should fail")
+	}
+}
@@ -0,0 +1,278 @@
+package mkhttpclient
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/zhongxuqi/mklibs/mkerr"
+	"github.com/zhongxuqi/mklibs/mklog"
+)
+
+// BreakerState ...
+type BreakerState int
+
+// BreakerState ...
+const (
+	BreakerClosed BreakerState = iota
+	BreakerOpen
+	BreakerHalfOpen
+)
+
+func (s BreakerState) String() string {
+	switch s {
+	case BreakerClosed:
+		return "closed"
+	case BreakerOpen:
+		return "open"
+	case BreakerHalfOpen:
+		return "half-open"
+	}
+	return fmt.Sprintf("state(%d)", int(s))
+}
+
+// BreakerConfig configures when a CircuitBreaker opens and how it recovers. The
+// breaker opens after ConsecutiveFailures failures in a row, or when the failure
+// rate of the current Window reaches FailureRate with at least MinRequests
+// requests. It stays open for OpenTimeout, then lets HalfOpenRequests trial
+// requests through and closes once they all succeed.
+type BreakerConfig struct {
+	ConsecutiveFailures int     // 5 when both thresholds are zero
+	FailureRate         float64 // between 0 and 1, 0 disables it
+	MinRequests         int     // 10 when zero
+	Window              time.Duration
+	OpenTimeout         time.Duration
+	HalfOpenRequests    int
+	OnStateChange       func(name string, from, to BreakerState) // called with the breaker unlocked
+}
+
+// CircuitBreaker fails calls fast with an unavailable error while its target
+// keeps failing. Its state changes are logged.
+type CircuitBreaker struct {
+	name   string
+	config BreakerConfig
+
+	mutex       sync.Mutex
+	state       BreakerState
+	generation  uint64
+	windowStart time.Time
+	requests    int
+	failures    int
+	consecutive int
+	openedAt    time.Time
+	trials      int
+	successes   int
+	transitions []breakerTransition // notified by unlock
+}
+
+// breakerTransition is a state change made with the breaker locked
+type breakerTransition struct {
+	from BreakerState
+	to   BreakerState
+}
+
+// NewCircuitBreaker ...
+func NewCircuitBreaker(name string, config BreakerConfig) *CircuitBreaker {
+	if config.ConsecutiveFailures <= 0 && config.FailureRate <= 0 {
+		config.ConsecutiveFailures = 5
+	}
+	if config.MinRequests <= 0 {
+		config.MinRequests = 10
+	}
+	if config.Window <= 0 {
+		config.Window = 10 * time.Second
+	}
+	if config.OpenTimeout <= 0 {
+		config.OpenTimeout = 10 * time.Second
+	}
+	if config.HalfOpenRequests <= 0 {
+		config.HalfOpenRequests = 1
+	}
+	return &CircuitBreaker{
+		name:        name,
+		config:      config,
+		windowStart: time.Now(),
+	}
+}
+
+// Name ...
+func (s *CircuitBreaker) Name() string {
+	return s.name
+}
+
+// State ...
+func (s *CircuitBreaker) State() BreakerState {
+	s.mutex.Lock()
+	defer s.unlock(context.TODO())
+	s.refresh(time.Now())
+	return s.state
+}
+
+// allow reports whether a request may be sent, returning the generation its
+// result is recorded with, or an unavailable error while the breaker is open
+func (s *CircuitBreaker) allow(ctx context.Context) (uint64, error) {
+	s.mutex.Lock()
+	defer s.unlock(ctx)
+	s.refresh(time.Now())
+	switch s.state {
+	case BreakerOpen:
+		return 0, mkerr.WithCategory(mkerr.NewErrorWithoutStack(ErrNoCircuitOpen, "circuit breaker open",
+			fmt.Sprintf("circuit breaker %s open", s.name)), mkerr.CategoryUnavailable)
+	case BreakerHalfOpen:
+		if s.trials >= s.config.HalfOpenRequests {
+			return 0, mkerr.WithCategory(mkerr.NewErrorWithoutStack(ErrNoCircuitOpen, "circuit breaker open",
+				fmt.Sprintf("circuit breaker %s half-open, too many trial requests", s.name)), mkerr.CategoryUnavailable)
+		}
+		s.trials++
+	}
+	return s.generation, nil
+}
+
+// record counts the result of a request allowed in generation
+func (s *CircuitBreaker) record(ctx context.Context, generation uint64, attempt RetryAttempt) {
+	s.mutex.Lock()
+	defer s.unlock(ctx)
+	now := time.Now()
+	s.refresh(now)
+	if generation != s.generation {
+		return
+	}
+	failed, counted := attemptFailed(attempt)
+	if !counted {
+		if s.state == BreakerHalfOpen {
+			s.trials--
+		}
+		return
+	}
+	success := !failed
+	switch s.state {
+	case BreakerClosed:
+		s.requests++
+		if success {
+			s.consecutive = 0
+			return
+		}
+		s.failures++
+		s.consecutive++
+		if s.config.ConsecutiveFailures > 0 && s.consecutive >= s.config.ConsecutiveFailures {
+			s.setState(BreakerOpen, now)
+		} else if s.config.FailureRate > 0 && s.requests >= s.config.MinRequests &&
+			float64(s.failures)/float64(s.requests) >= s.config.FailureRate {
+			s.setState(BreakerOpen, now)
+		}
+	case BreakerHalfOpen:
+		if !success {
+			s.setState(BreakerOpen, now)
+			return
+		}
+		s.successes++
+		if s.successes >= s.config.HalfOpenRequests {
+			s.setState(BreakerClosed, now)
+		}
+	}
+}
+
+// refresh moves an open breaker to half-open after OpenTimeout and starts a new
+// counting window when the current one is over
+func (s *CircuitBreaker) refresh(now time.Time) {
+	switch s.state {
+	case BreakerOpen:
+		if now.Sub(s.openedAt) >= s.config.OpenTimeout {
+			s.setState(BreakerHalfOpen, now)
+		}
+	case BreakerClosed:
+		if now.Sub(s.windowStart) >= s.config.Window {
+			s.windowStart = now
+			s.requests = 0
+			s.failures = 0
+		}
+	}
+}
+
+// setState moves the breaker to state, the change is notified by unlock
+func (s *CircuitBreaker) setState(state BreakerState, now time.Time) {
+	from := s.state
+	s.state = state
+	s.generation++
+	s.windowStart = now
+	s.requests = 0
+	s.failures = 0
+	s.consecutive = 0
+	s.trials = 0
+	s.successes = 0
+	if state == BreakerOpen {
+		s.openedAt = now
+	}
+
+	s.transitions = append(s.transitions, breakerTransition{from: from, to: state})
+}
+
+// unlock unlocks the breaker, then logs the state changes made while it was
+// locked and calls OnStateChange, which may use the breaker again
+func (s *CircuitBreaker) unlock(ctx context.Context) {
+	transitions := s.transitions
+	s.transitions = nil
+	s.mutex.Unlock()
+
+	ml := mklog.NewWithContext(ctx)
+	for _, transition := range transitions {
+		if transition.to == BreakerOpen {
+			ml.Errorf("circuit breaker %s state %s -> %s", s.name, transition.from, transition.to)
+		} else {
+			ml.Infof("circuit breaker %s state %s -> %s", s.name, transition.from, transition.to)
+		}
+		if s.config.OnStateChange != nil {
+			s.config.OnStateChange(s.name, transition.from, transition.to)
+		}
+	}
+}
+
+// HostCircuitBreakers holds a CircuitBreaker per host, created on first use
+type HostCircuitBreakers struct {
+	config   BreakerConfig
+	mutex    sync.Mutex
+	breakers map[string]*CircuitBreaker
+}
+
+// NewHostCircuitBreakers ...
+func NewHostCircuitBreakers(config BreakerConfig) *HostCircuitBreakers {
+	return &HostCircuitBreakers{
+		config:   config,
+		breakers: make(map[string]*CircuitBreaker),
+	}
+}
+
+// Get returns the breaker of host
+func (s *HostCircuitBreakers) Get(host string) *CircuitBreaker {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	breaker, ok := s.breakers[host]
+	if !ok {
+		breaker = NewCircuitBreaker(host, s.config)
+		s.breakers[host] = breaker
+	}
+	return breaker
+}
+
+// attemptFailed reports whether attempt failed because of its target: transport
+// errors and 5xx statuses. Canceled attempts are not counted.
+func attemptFailed(attempt RetryAttempt) (failed bool, counted bool) {
+	if attempt.StatusCode == 0 {
+		if attempt.Err == nil {
+			return false, true
+		}
+		return true, !mkerr.IsCanceled(attempt.Err)
+	}
+	return attempt.StatusCode/100 == 5, true
+}
+
+// requestHost returns the host of rawURL
+func requestHost(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return ""
+	}
+	return u.Host
+}
@@ -0,0 +1,110 @@
+package mkhttpclient
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/zhongxuqi/mklibs/mkerr"
+)
+
+func TestCircuitBreaker(t *testing.T) {
+	changes := make([]BreakerState, 0)
+	var breaker *CircuitBreaker
+	breaker = NewCircuitBreaker("test", BreakerConfig{
+		ConsecutiveFailures: 2,
+		OpenTimeout:         50 * time.Millisecond,
+		OnStateChange: func(name string, from, to BreakerState) {
+			// 回调时熔断器未加锁, 可以再调用它
+			if state := breaker.State(); state != to {
+				t.Errorf("OnStateChange state %s, want %s", state, to)
+			}
+			changes = append(changes, to)
+		},
+	})
+	failure := RetryAttempt{StatusCode: http.StatusInternalServerError}
+	success := RetryAttempt{StatusCode: http.StatusOK}
+
+	for i := 0; i < 2; i++ {
+		generation, err := breaker.allow(context.TODO())
+		if err != nil {
+			t.Fatalf("breaker.allow error %+v", err)
+		}
+		breaker.record(context.TODO(), generation, failure)
+	}
+	if breaker.State() != BreakerOpen {
+		t.Fatalf("breaker state %s", breaker.State())
+	}
+	if _, err := breaker.allow(context.TODO()); !mkerr.IsUnavailable(err) || err.(mkerr.Error).ErrNo() != ErrNoCircuitOpen {
+		t.Fatalf("breaker.allow error %+v", err)
+	}
+
+	// 半开状态只允许一个试探请求, 取消的请求不计数
+	time.Sleep(60 * time.Millisecond)
+	generation, err := breaker.allow(context.TODO())
+	if err != nil || breaker.State() != BreakerHalfOpen {
+		t.Fatalf("breaker.allow error %+v state %s", err, breaker.State())
+	}
+	if _, err := breaker.allow(context.TODO()); err == nil {
+		t.Fatalf("breaker.allow should fail")
+	}
+	breaker.record(context.TODO(), generation, RetryAttempt{Err: mkerr.Canceled(ErrNoCanceled, "http request canceled", "")})
+	if generation, err = breaker.allow(context.TODO()); err != nil {
+		t.Fatalf("breaker.allow error %+v", err)
+	}
+	breaker.record(context.TODO(), generation, success)
+	if breaker.State() != BreakerClosed {
+		t.Fatalf("breaker state %s", breaker.State())
+	}
+	// 旧状态的结果被忽略
+	breaker.record(context.TODO(), generation-1, failure)
+	breaker.record(context.TODO(), generation-1, failure)
+	if breaker.State() != BreakerClosed {
+		t.Fatalf("breaker state %s", breaker.State())
+	}
+	if len(changes) != 3 || changes[0] != BreakerOpen || changes[1] != BreakerHalfOpen || changes[2] != BreakerClosed {
+		t.Fatalf("state changes %+v", changes)
+	}
+}
+
+func TestCircuitBreakerFailureRate(t *testing.T) {
+	breaker := NewCircuitBreaker("test", BreakerConfig{FailureRate: 0.5, MinRequests: 4})
+	for _, statusCode := range []int{http.StatusOK, http.StatusBadGateway, http.StatusOK} {
+		generation, _ := breaker.allow(context.TODO())
+		breaker.record(context.TODO(), generation, RetryAttempt{StatusCode: statusCode})
+	}
+	if breaker.State() != BreakerClosed {
+		t.Fatalf("breaker state %s", breaker.State())
+	}
+	generation, _ := breaker.allow(context.TODO())
+	breaker.record(context.TODO(), generation, RetryAttempt{Err: mkerr.Unavailable(ErrNoUnavailable, "http service unavailable", "")})
+	if breaker.State() != BreakerOpen {
+		t.Fatalf("breaker state %s", breaker.State())
+	}
+}
+
+func TestHttpCircuitBreaker(t *testing.T) {
+	var count int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&count, 1)
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer server.Close()
+	breakers := NewHostCircuitBreakers(BreakerConfig{ConsecutiveFailures: 3, OpenTimeout: time.Minute})
+	client := newTestClient(t, server.URL, WithHostCircuitBreakers(breakers))
+	defer client.(*httpClient).client.CloseIdleConnections()
+
+	var res testRes
+	if err := client.GetEx(context.TODO(), "/", nil, &res, nil, WithRetryTimes(5), WithRetryHttpError(true)); StatusCode(err) != http.StatusInternalServerError {
+		t.Fatalf("client.GetEx error %+v", err)
+	}
+	if err := client.Get(context.TODO(), "/", nil, &res); !mkerr.IsUnavailable(err) {
+		t.Fatalf("client.Get error %+v", err)
+	}
+	if atomic.LoadInt32(&count) != 3 || breakers.Get(requestHost(server.URL)).State() != BreakerOpen {
+		t.Fatalf("server called %d times", count)
+	}
+}
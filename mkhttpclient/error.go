@@ -0,0 +1,138 @@
+package mkhttpclient
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+
+	"github.com/zhongxuqi/mklibs/mkerr"
+)
+
+// const ...
+const (
+	DefaultErrNoField  = "errno"
+	DefaultErrMsgField = "errmsg"
+
+	// errnos of the transport failures, negative not to clash with business errnos
+	ErrNoTimeout     int64 = -1
+	ErrNoUnavailable int64 = -2
+	ErrNoCanceled    int64 = -3
+	ErrNoCircuitOpen int64 = -4
+	ErrNoDecode      int64 = -5
+)
+
+// HTTPError is returned for non-2xx responses, and for 2xx responses whose body
+// holds a business error when error decoding is enabled. Err is the decoded error.
+type HTTPError struct {
+	StatusCode int
+	Status     string
+	Err        mkerr.Error
+}
+
+// Error ...
+func (s *HTTPError) Error() string {
+	if s.Err != nil {
+		return fmt.Sprintf("http error %d %s: [%d] %s", s.StatusCode, s.Status, s.Err.ErrNo(), s.Err.Error())
+	}
+	return fmt.Sprintf("http error %d %s", s.StatusCode, s.Status)
+}
+
+// Category returns the category of the decoded error, else the one of the status,
+// e.g. mkerr.CategoryUnavailable for 502 and 503.
+func (s *HTTPError) Category() mkerr.Category {
+	if s.Err != nil {
+		if category := mkerr.CategoryOf(s.Err); category != mkerr.CategoryUnknown {
+			return category
+		}
+	}
+	return mkerr.CategoryFromHTTPStatus(s.StatusCode)
+}
+
+// Unwrap returns the decoded error, so errors.As finds the mkerr.Error.
+func (s *HTTPError) Unwrap() error {
+	if s.Err == nil {
+		return nil
+	}
+	return s.Err
+}
+
+// StatusCode returns the HTTP status carried by err, or 0 when err holds no HTTPError.
+func StatusCode(err error) int {
+	var httpErr *HTTPError
+	if errors.As(err, &httpErr) {
+		return httpErr.StatusCode
+	}
+	return 0
+}
+
+// transportError converts an error of http.Client.Do into an mkerr.Error of the
+// timeout, canceled or unavailable category.
+func transportError(err error) error {
+	switch {
+	case errors.Is(err, context.Canceled):
+		return mkerr.WithCategory(mkerr.Wrap(err, ErrNoCanceled, "http request canceled"), mkerr.CategoryCanceled)
+	case mkerr.IsTimeout(err):
+		return mkerr.WithCategory(mkerr.Wrap(err, ErrNoTimeout, "http request timeout"), mkerr.CategoryTimeout)
+	}
+	return mkerr.WithCategory(mkerr.Wrap(err, ErrNoUnavailable, "http service unavailable"), mkerr.CategoryUnavailable)
+}
+
+// ErrorDecoder decodes the business error of a response body. It returns nil
+// when the body holds no error.
+type ErrorDecoder interface {
+	DecodeError(statusCode int, body []byte) mkerr.Error
+}
+
+// ErrorDecoderFunc ...
+type ErrorDecoderFunc func(statusCode int, body []byte) mkerr.Error
+
+// DecodeError ...
+func (f ErrorDecoderFunc) DecodeError(statusCode int, body []byte) mkerr.Error {
+	return f(statusCode, body)
+}
+
+// fieldErrorDecoder decodes a JSON object body holding a non-zero errno field.
+type fieldErrorDecoder struct {
+	errNoField  string
+	errMsgField string
+}
+
+// NewFieldErrorDecoder returns an ErrorDecoder reading the errno and message
+// from the given fields of a JSON object body. The errno may be a number or a
+// numeric string, an errno of 0 means success.
+func NewFieldErrorDecoder(errNoField, errMsgField string) ErrorDecoder {
+	return &fieldErrorDecoder{
+		errNoField:  errNoField,
+		errMsgField: errMsgField,
+	}
+}
+
+// DecodeError ...
+func (s *fieldErrorDecoder) DecodeError(statusCode int, body []byte) mkerr.Error {
+	fields := make(map[string]json.RawMessage)
+	if err := json.Unmarshal(body, &fields); err != nil {
+		return nil
+	}
+	rawErrNo, ok := fields[s.errNoField]
+	if !ok {
+		return nil
+	}
+	var errNo int64
+	if err := json.Unmarshal(rawErrNo, &errNo); err != nil {
+		var errNoStr string
+		if err = json.Unmarshal(rawErrNo, &errNoStr); err != nil {
+			return nil
+		}
+		if errNo, err = strconv.ParseInt(errNoStr, 10, 64); err != nil {
+			return nil
+		}
+	}
+	if errNo == 0 {
+		return nil
+	}
+	var errMsg string
+	json.Unmarshal(fields[s.errMsgField], &errMsg)
+	return mkerr.NewErrorWithoutStack(errNo, errMsg, fmt.Sprintf("remote error, http status %d", statusCode))
+}
@@ -0,0 +1,127 @@
+package mkhttpclient
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/zhongxuqi/mklibs/mkerr"
+)
+
+func TestDecodeError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/business":
+			w.Write([]byte(`{"errno":10404,"errmsg":"user not found"}`))
+		case "/success":
+			w.Write([]byte(`{"errno":0,"errmsg":"success"}`))
+		case "/custom":
+			w.WriteHeader(http.StatusServiceUnavailable)
+			w.Write([]byte(`{"code":"10503","message":"overloaded"}`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	// 不解析错误
+	client := newTestClient(t, server.URL)
+	var res testRes
+	if err := client.Get(context.TODO(), "/business", nil, &res); err != nil || res.ErrNo != 10404 {
+		t.Fatalf("client.Get error %+v %+v", err, res)
+	}
+	if err := client.Get(context.TODO(), "/notfound", nil, &res); StatusCode(err) != http.StatusNotFound {
+		t.Fatalf("client.Get error %+v", err)
+	}
+
+	// 解析错误
+	client = newTestClient(t, server.URL, WithDecodeError(true))
+	err := client.Get(context.TODO(), "/business", nil, &res)
+	var mkErr mkerr.Error
+	if !errors.As(err, &mkErr) || mkErr.ErrNo() != 10404 || mkErr.Error() != "user not found" || StatusCode(err) != http.StatusOK {
+		t.Fatalf("client.Get error %+v", err)
+	}
+	if err := client.Get(context.TODO(), "/success", nil, &res); err != nil || res.ErrMsg != "success" {
+		t.Fatalf("client.Get error %+v", err)
+	}
+	if err := client.Get(context.TODO(), "/notfound", nil, &res); StatusCode(err) != http.StatusNotFound || errors.As(err, &mkErr) {
+		t.Fatalf("client.Get error %+v", err)
+	}
+
+	// 自定义字段
+	err = client.GetEx(context.TODO(), "/custom", nil, &res, nil, WithErrorFields("code", "message"))
+	if !errors.As(err, &mkErr) || mkErr.ErrNo() != 10503 || mkErr.Error() != "overloaded" || StatusCode(err) != http.StatusServiceUnavailable {
+		t.Fatalf("client.GetEx error %+v", err)
+	}
+
+	// 自定义解析
+	decoder := ErrorDecoderFunc(func(statusCode int, body []byte) mkerr.Error {
+		if statusCode == http.StatusNotFound {
+			return mkerr.NewError(404, "not found", string(body))
+		}
+		return nil
+	})
+	err = client.GetEx(context.TODO(), "/notfound", nil, &res, nil, WithErrorDecoder(decoder))
+	if !errors.As(err, &mkErr) || mkErr.ErrNo() != 404 || StatusCode(err) != http.StatusNotFound {
+		t.Fatalf("client.GetEx error %+v", err)
+	}
+}
+
+func TestErrorCategory(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/unavailable":
+			w.WriteHeader(http.StatusServiceUnavailable)
+		case "/slow":
+			time.Sleep(500 * time.Millisecond)
+		case "/business":
+			w.Write([]byte(`{"errno":10404,"errmsg":"user not found","category":"not_found"}`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	client := newTestClient(t, server.URL, WithTotalTimeout(100*time.Millisecond))
+	var res testRes
+	if err := client.Get(context.TODO(), "/unavailable", nil, &res); !mkerr.IsUnavailable(err) || !mkerr.IsRetryable(err) {
+		t.Fatalf("client.Get error %+v", err)
+	}
+	if err := client.Get(context.TODO(), "/notfound", nil, &res); !mkerr.IsNotFound(err) {
+		t.Fatalf("client.Get error %+v", err)
+	}
+	if err := client.Get(context.TODO(), "/slow", nil, &res); !mkerr.IsTimeout(err) {
+		t.Fatalf("client.Get error %+v", err)
+	}
+	ctx, cancel := context.WithCancel(context.TODO())
+	cancel()
+	if err := client.Get(ctx, "/slow", nil, &res); !mkerr.IsCanceled(err) {
+		t.Fatalf("client.Get error %+v", err)
+	}
+	decoder := ErrorDecoderFunc(func(statusCode int, body []byte) mkerr.Error {
+		var remoteErr mkerr.MkErr
+		if err := json.Unmarshal(body, &remoteErr); err != nil || remoteErr.ErrNo() == 0 {
+			return nil
+		}
+		return &remoteErr
+	})
+	if err := client.GetEx(context.TODO(), "/business", nil, &res, nil, WithErrorDecoder(decoder)); !mkerr.IsNotFound(err) {
+		t.Fatalf("client.GetEx error %+v", err)
+	}
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("net.Listen error %+v", err)
+	}
+	addr := listener.Addr().String()
+	listener.Close()
+	client = newTestClient(t, "http://"+addr)
+	if err := client.Get(context.TODO(), "/", nil, &res); !mkerr.IsUnavailable(err) {
+		t.Fatalf("client.Get error %+v", err)
+	}
+}
@@ -7,12 +7,15 @@ import (
 	"fmt"
 	"io"
 	"io/ioutil"
-	"mime/multipart"
 	"net/http"
 	"net/url"
+	"sort"
+	"strings"
+	"sync/atomic"
 	"time"
 
 	"github.com/zhongxuqi/mklibs/common"
+	"github.com/zhongxuqi/mklibs/mkerr"
 	"github.com/zhongxuqi/mklibs/mklog"
 )
 
@@ -23,13 +26,18 @@ const (
 )
 
 var (
-	defaultBaseConfig = baseConfig{
+	defaultBaseConfig = Config{
 		MaxIdleConns:       10,
 		IdleConnTimeout:    time.Duration(30 * time.Second),
 		DisableCompression: true,
 		RetryTimes:         0,
 		RetryTimeout:       time.Duration(5 * time.Second),
 		TotalTimeout:       time.Duration(5 * time.Second),
+		CallTimeout:        0,
+		ErrorDecoder:       NewFieldErrorDecoder(DefaultErrNoField, DefaultErrMsgField),
+		EjectFailures:      0,
+		EjectDuration:      time.Duration(30 * time.Second),
+		LogBodyLimit:       4096,
 	}
 )
 
@@ -46,20 +54,65 @@ type HTTPClient interface {
 	PostEx(ctx context.Context, path string, params map[string]string, res interface{}, header map[string]string, options ...HTTPClientOption) error
 	Post(ctx context.Context, path string, params map[string]string, res interface{}) error
 	PostFileEx(ctx context.Context, path string, files map[string][]byte, res interface{}, header map[string]string, options ...HTTPClientOption) error
+	SendEx(ctx context.Context, method, path string, body io.Reader, res interface{}, header map[string]string, options ...HTTPClientOption) error
+	Stream(ctx context.Context, method, path string, body io.Reader, header map[string]string, options ...HTTPClientOption) (io.ReadCloser, error)
+	PostMultipartEx(ctx context.Context, path string, form *MultipartForm, res interface{}, header map[string]string, options ...HTTPClientOption) error
 }
 
 type httpClient struct {
-	host    string
-	options []HTTPClientOption
-	client  *http.Client
+	endpoints *endpointSet
+	balancer  Balancer
+	options   []HTTPClientOption
+	client    *http.Client
 }
 
-// NewHTTPClient ...
-func NewHTTPClient(host string, options ...HTTPClientOption) HTTPClient {
-	currConfig := parseBaseConfig(defaultBaseConfig, options)
+// NewHTTPClient returns an HTTPClient sending its requests to host, it fails
+// when an option is invalid
+func NewHTTPClient(host string, options ...HTTPClientOption) (HTTPClient, error) {
+	return NewMultiHostHTTPClient([]string{host}, options...)
+}
+
+// MustNewHTTPClient is NewHTTPClient panicking when an option is invalid, for
+// clients declared as package variables
+func MustNewHTTPClient(host string, options ...HTTPClientOption) HTTPClient {
+	client, err := NewHTTPClient(host, options...)
+	if err != nil {
+		panic(fmt.Sprintf("mkhttpclient: %+v", err))
+	}
+	return client
+}
+
+// NewMultiHostHTTPClient returns an HTTPClient balancing its requests over the
+// replicas hosts, see WithBalancer and WithEjection. Retries go to another
+// host when there is one.
+func NewMultiHostHTTPClient(hosts []string, options ...HTTPClientOption) (HTTPClient, error) {
+	if len(hosts) == 0 {
+		return nil, fmt.Errorf("no hosts")
+	}
+	return newHTTPClient(newEndpointSet(hosts), options)
+}
+
+// host returns the host of the client for logging, the hosts when there are several
+func (s *httpClient) host() string {
+	hosts := s.endpoints.hosts()
+	if len(hosts) == 1 {
+		return hosts[0]
+	}
+	return "{" + strings.Join(hosts, ",") + "}"
+}
+
+func newHTTPClient(endpoints *endpointSet, options []HTTPClientOption) (*httpClient, error) {
+	currConfig, err := parseBaseConfig(defaultBaseConfig, options)
+	if err != nil {
+		return nil, err
+	}
+	if currConfig.Response != nil {
+		return nil, fmt.Errorf("WithResponse is a call option")
+	}
 	return &httpClient{
-		host:    host,
-		options: options,
+		endpoints: endpoints,
+		balancer:  NewRoundRobinBalancer(),
+		options:   options,
 		client: &http.Client{
 			Transport: &http.Transport{
 				MaxIdleConns:       currConfig.MaxIdleConns,
@@ -67,53 +120,51 @@ func NewHTTPClient(host string, options ...HTTPClientOption) HTTPClient {
 				DisableCompression: currConfig.DisableCompression,
 			},
 		},
-	}
+	}, nil
 }
 
 // GetEx ...
 func (s *httpClient) GetEx(ctx context.Context, path string, params map[string]interface{}, res interface{}, header map[string]string, options ...HTTPClientOption) error {
 	ml := mklog.NewWithContext(ctx)
-	if len(params) > 0 {
-		path += "?"
-		for k, v := range params {
-			path += fmt.Sprintf("%s=%+v&", k, v)
-		}
+	path, err := pathWithQuery(path, params)
+	if err != nil {
+		ml.Errorf("pathWithQuery error %+v", err)
+		return err
 	}
-	ml.Infof("%s%s", s.host, path)
-	return s.do(ctx, http.MethodGet, fmt.Sprintf("%s%s", s.host, path), nil, res, header, options...)
+	ml.Infof("%s%s", s.host(), path)
+	return s.do(ctx, http.MethodGet, path, nil, res, header, options...)
 }
 
 // Get ...
 func (s *httpClient) Get(ctx context.Context, path string, params map[string]interface{}, res interface{}) error {
 	ml := mklog.NewWithContext(ctx)
-	if len(params) > 0 {
-		path += "?"
-		for k, v := range params {
-			path += fmt.Sprintf("%s=%+v&", k, v)
-		}
+	path, err := pathWithQuery(path, params)
+	if err != nil {
+		ml.Errorf("pathWithQuery error %+v", err)
+		return err
 	}
-	ml.Infof("url %+v%+v", s.host, path)
-	return s.do(ctx, http.MethodGet, fmt.Sprintf("%s%s", s.host, path), nil, res, nil)
+	ml.Infof("url %+v%+v", s.host(), path)
+	return s.do(ctx, http.MethodGet, path, nil, res, nil)
 }
 
 // DeleteEx ...
 func (s *httpClient) DeleteEx(ctx context.Context, path string, res interface{}, header map[string]string, options ...HTTPClientOption) error {
 	ml := mklog.NewWithContext(ctx)
-	ml.Infof("url [%s]%s%s header %+v", http.MethodDelete, s.host, path, header)
-	return s.do(ctx, http.MethodDelete, fmt.Sprintf("%s%s", s.host, path), nil, res, header, options...)
+	ml.Infof("url [%s]%s%s header %+v", http.MethodDelete, s.host(), path, header)
+	return s.do(ctx, http.MethodDelete, path, nil, res, header, options...)
 }
 
 // Delete ...
 func (s *httpClient) Delete(ctx context.Context, path string, res interface{}) error {
 	ml := mklog.NewWithContext(ctx)
-	ml.Infof("url [%s]%s%s", http.MethodDelete, s.host, path)
-	return s.do(ctx, http.MethodDelete, fmt.Sprintf("%s%s", s.host, path), nil, res, nil)
+	ml.Infof("url [%s]%s%s", http.MethodDelete, s.host(), path)
+	return s.do(ctx, http.MethodDelete, path, nil, res, nil)
 }
 
 // PutJSONEx ...
 func (s *httpClient) PutJSONEx(ctx context.Context, path string, params interface{}, res interface{}, header map[string]string, options ...HTTPClientOption) error {
 	ml := mklog.NewWithContext(ctx)
-	ml.Infof("url [%s]%s%s params %+v header %+v", http.MethodPut, s.host, path, params, header)
+	ml.Infof("url [%s]%s%s params %+v header %+v", http.MethodPut, s.host(), path, params, header)
 	paramsByte := make([]byte, 0)
 	if params != nil {
 		var err error
@@ -128,13 +179,13 @@ func (s *httpClient) PutJSONEx(ctx context.Context, path string, params interfac
 	}
 	header["Content-Type"] = ContentTypeJSON
 	ml.Infof("params %+v", string(paramsByte))
-	return s.do(ctx, http.MethodPut, fmt.Sprintf("%s%s", s.host, path), paramsByte, res, header, options...)
+	return s.do(ctx, http.MethodPut, path, bytesBody(paramsByte), res, header, options...)
 }
 
 // PutJSON ...
 func (s *httpClient) PutJSON(ctx context.Context, path string, params interface{}, res interface{}) error {
 	ml := mklog.NewWithContext(ctx)
-	ml.Infof("url [%s]%s%s params %+v", http.MethodPut, s.host, path, params)
+	ml.Infof("url [%s]%s%s params %+v", http.MethodPut, s.host(), path, params)
 	paramsByte := make([]byte, 0)
 	if params != nil {
 		var err error
@@ -145,7 +196,7 @@ func (s *httpClient) PutJSON(ctx context.Context, path string, params interface{
 		}
 	}
 	ml.Infof("params %+v", string(paramsByte))
-	return s.do(ctx, http.MethodPut, fmt.Sprintf("%s%s", s.host, path), paramsByte, res, map[string]string{"Content-Type": ContentTypeJSON})
+	return s.do(ctx, http.MethodPut, path, bytesBody(paramsByte), res, map[string]string{"Content-Type": ContentTypeJSON})
 }
 
 // PostJSONEx ...
@@ -165,7 +216,7 @@ func (s *httpClient) PostJSONEx(ctx context.Context, path string, params interfa
 	}
 	ml.Infof("params %+v", string(paramsByte))
 	header["Content-Type"] = ContentTypeJSON
-	return s.do(ctx, http.MethodPost, fmt.Sprintf("%s%s", s.host, path), paramsByte, res, header, options...)
+	return s.do(ctx, http.MethodPost, path, bytesBody(paramsByte), res, header, options...)
 }
 
 // PostJSON ...
@@ -180,8 +231,8 @@ func (s *httpClient) PostJSON(ctx context.Context, path string, params interface
 			return err
 		}
 	}
-	ml.Infof("[POST]%+v body: %+v", fmt.Sprintf("%s%s", s.host, path), string(paramsByte))
-	return s.do(ctx, http.MethodPost, fmt.Sprintf("%s%s", s.host, path), paramsByte, res, map[string]string{"Content-Type": ContentTypeJSON})
+	ml.Infof("[POST]%+v body: %+v", fmt.Sprintf("%s%s", s.host(), path), string(paramsByte))
+	return s.do(ctx, http.MethodPost, path, bytesBody(paramsByte), res, map[string]string{"Content-Type": ContentTypeJSON})
 }
 
 // PostEx ...
@@ -195,12 +246,12 @@ func (s *httpClient) PostEx(ctx context.Context, path string, params map[string]
 		}
 		paramsByte = []byte(values.Encode())
 	}
-	ml.Infof("[POST]%+v body: %+v", fmt.Sprintf("%s%s", s.host, path), string(paramsByte))
+	ml.Infof("[POST]%+v body: %+v", fmt.Sprintf("%s%s", s.host(), path), string(paramsByte))
 	if header == nil {
 		header = make(map[string]string)
 	}
 	header["Content-Type"] = ContentTypeForm
-	return s.do(ctx, http.MethodPost, fmt.Sprintf("%s%s", s.host, path), paramsByte, res, header, options...)
+	return s.do(ctx, http.MethodPost, path, bytesBody(paramsByte), res, header, options...)
 }
 
 // Post ...
@@ -214,135 +265,281 @@ func (s *httpClient) Post(ctx context.Context, path string, params map[string]st
 		}
 		paramsByte = []byte(values.Encode())
 	}
-	ml.Infof("[POST]%+v body: %+v", fmt.Sprintf("%s%s", s.host, path), string(paramsByte))
-	return s.do(ctx, http.MethodPost, fmt.Sprintf("%s%s", s.host, path), paramsByte, res, map[string]string{"Content-Type": ContentTypeForm})
+	ml.Infof("[POST]%+v body: %+v", fmt.Sprintf("%s%s", s.host(), path), string(paramsByte))
+	return s.do(ctx, http.MethodPost, path, bytesBody(paramsByte), res, map[string]string{"Content-Type": ContentTypeForm})
 }
 
+// PostFileEx posts files as a multipart/form-data body, each file in the field
+// named after it. Use PostMultipartEx for field names, content types and text
+// fields.
 func (s *httpClient) PostFileEx(ctx context.Context, path string, files map[string][]byte, res interface{}, header map[string]string, options ...HTTPClientOption) error {
-	ml := mklog.NewWithContext(ctx)
-	ml.Infof("url [%s]%s%s", http.MethodPost, s.host, path)
-	body := bytes.NewBuffer([]byte(""))
-	writer := multipart.NewWriter(body)
-	var contentLength int64
-	for fileName, fileContent := range files {
-		part, err := writer.CreateFormFile(fileName, fileName)
-		if err != nil {
-			ml.Errorf("writer.CreateFormFile error %+v", err.Error())
-			return err
-		}
-		_, err = io.Copy(part, bytes.NewReader(fileContent))
-		if err != nil {
-			ml.Errorf("bytes.NewReader error %+v", err.Error())
-			return err
-		}
-		contentLength += int64(len(fileContent))
-		err = writer.Close()
-		if err != nil {
-			ml.Errorf("writer.Close error %+v", err.Error())
-			return err
-		}
+	fileNames := make([]string, 0, len(files))
+	for fileName := range files {
+		fileNames = append(fileNames, fileName)
 	}
-	if header == nil {
-		header = make(map[string]string)
+	sort.Strings(fileNames)
+	form := NewMultipartForm()
+	for _, fileName := range fileNames {
+		form.AddFile(fileName, fileName, "", files[fileName])
 	}
-	header["Content-Type"] = writer.FormDataContentType()
-	//header["Content-Length"] = fmt.Sprintf("%d", contentLength)
-	return s.do(ctx, http.MethodPost, fmt.Sprintf("%s%s", s.host, path), body.Bytes(), res, header, options...)
+	return s.PostMultipartEx(ctx, path, form, res, header, options...)
 }
 
-func (s *httpClient) do(ctx context.Context, method, url string, bodyByte []byte, res interface{}, header map[string]string, options ...HTTPClientOption) error {
+func (s *httpClient) do(ctx context.Context, method, path string, body *requestBody, res interface{}, header map[string]string, options ...HTTPClientOption) error {
 	ml := mklog.NewWithContext(ctx)
 	allOptions := make([]HTTPClientOption, 0, len(s.options)+len(options))
 	allOptions = append(allOptions, s.options...)
 	allOptions = append(allOptions, options...)
-	currConfig := parseBaseConfig(defaultBaseConfig, allOptions)
-	var httpRes *http.Response
+	currConfig, err := parseBaseConfig(defaultBaseConfig, allOptions)
+	if err != nil {
+		ml.Errorf("parseBaseConfig error %+v", err)
+		return err
+	}
+	if path, err = appendQuery(path, currConfig.Query); err != nil {
+		ml.Errorf("appendQuery error %+v", err)
+		return err
+	}
+	// CallTimeout限制整个调用, 包括重试和重试间隔
+	var cancel context.CancelFunc
+	if currConfig.CallTimeout > 0 {
+		ctx, cancel = context.WithTimeout(ctx, currConfig.CallTimeout)
+		defer func() {
+			if cancel != nil {
+				cancel()
+			}
+		}()
+	}
 
-	resChan := make(chan *http.Response)
+	retryPolicy := currConfig.RetryPolicy
+	if retryPolicy == nil {
+		retryPolicy = defaultRetryPolicy{retryHttpError: currConfig.RetryHttpError}
+	}
+	balancer := currConfig.Balancer
+	if balancer == nil {
+		balancer = s.balancer
+	}
+	tried := make(map[*Endpoint]bool)
+	start := time.Now()
+	var lastErr error
 	for i := 0; i < currConfig.RetryTimes+1; i++ {
-		var buf *bytes.Buffer = nil
-		if bodyByte != nil {
-			buf = bytes.NewBuffer(bodyByte)
-		} else {
-			buf = bytes.NewBuffer([]byte(""))
+		if err := ctx.Err(); err != nil {
+			ml.Errorf("req %s %s error %+v", method, path, err)
+			return transportError(err)
 		}
-		req, err := http.NewRequest(method, url, buf)
-		if err != nil {
-			ml.Errorf("http.NewRequest error %+v", err.Error())
-			return err
+		endpoint := s.endpoints.pick(balancer, currConfig.BalanceKey, tried)
+		if endpoint == nil {
+			ml.Errorf("req %s %s error no endpoint", method, path)
+			if lastErr != nil {
+				return lastErr
+			}
+			return mkerr.Unavailable(ErrNoUnavailable, "http service unavailable", fmt.Sprintf("req %s %s error no endpoint", method, path))
 		}
-
-		// add http headers
-		req.Header.Set(common.HttpLogID, ml.GetLogID())
-		for k, v := range header {
-			req.Header.Add(k, v)
+		tried[endpoint] = true
+		url := endpoint.Host + path
+		// 最后一次请求使用TotalTimeout, 之前的请求使用RetryTimeout
+		timeout := currConfig.RetryTimeout
+		lastAttempt := i == currConfig.RetryTimes
+		if lastAttempt {
+			timeout = currConfig.TotalTimeout
 		}
-
-		errChan := make(chan error)
-		go func() {
-			res, err := s.client.Do(req)
-			if err != nil {
-				ml.Errorf("client.Do error %+v", err)
-				errChan <- err
-				return
-			}
-			resChan <- res
-		}()
-		if i < currConfig.RetryTimes {
-			select {
-			case httpRes = <-resChan:
-				if httpStatusCode, err := parseRes(ctx, httpRes, res); httpStatusCode/100 != 2 && currConfig.RetryHttpError {
-					ml.Errorf("parseRes error %+v", err)
-				} else if err != nil {
-					ml.Errorf("parseRes error %+v", err)
-					return err
-				} else {
-					return nil
+		breaker := currConfig.CircuitBreaker
+		if currConfig.HostBreakers != nil {
+			breaker = currConfig.HostBreakers.Get(requestHost(url))
+		}
+		var generation uint64
+		if breaker != nil {
+			if generation, err = breaker.allow(ctx); err != nil {
+				ml.Errorf("req %s %s error %+v", method, url, err)
+				// 重试时熔断, 返回上次请求的错误
+				if lastErr != nil {
+					return lastErr
 				}
-			case err := <-errChan:
-				ml.Errorf("client.Do error %+v", err)
-			case <-time.After(currConfig.RetryTimeout):
-				ml.Infof("retry %+v", i)
+				return err
+			}
+		}
+		if currConfig.Response != nil {
+			*currConfig.Response = Response{}
+		}
+		atomic.AddInt64(&endpoint.outstanding, 1)
+		attempt := s.doAttempt(ctx, timeout, method, url, body, res, header, currConfig, i+1)
+		atomic.AddInt64(&endpoint.outstanding, -1)
+		if currConfig.Response != nil {
+			currConfig.Response.Attempts = i + 1
+			currConfig.Response.Elapsed = time.Since(start)
+		}
+		endpoint.record(attempt, currConfig.EjectFailures, currConfig.EjectDuration)
+		if breaker != nil {
+			breaker.record(ctx, generation, attempt)
+		}
+		if attempt.Err == nil {
+			if currConfig.RetryBudget != nil {
+				currConfig.RetryBudget.deposit()
 			}
-		} else {
+			if cancel != nil && currConfig.stream != nil && *currConfig.stream != nil {
+				// 流式返回时由body的Close取消
+				stream := (*currConfig.stream).(*streamBody)
+				stream.cancels = append(stream.cancels, cancel)
+				cancel = nil
+			}
+			return nil
+		}
+		if lastAttempt || ctx.Err() != nil {
+			ml.Errorf("http error %+v", attempt.Err)
+			return attempt.Err
+		}
+		lastErr = attempt.Err
+		if body != nil && body.oneShot {
+			ml.Errorf("http error %+v, %s", attempt.Err, errBodyNotReplayable)
+			return attempt.Err
+		}
+		retry, delay := retryPolicy.Retry(attempt)
+		if !retry {
+			ml.Errorf("parseRes error %+v", attempt.Err)
+			return attempt.Err
+		}
+		if currConfig.RetryBudget != nil && !currConfig.RetryBudget.withdraw() {
+			ml.Errorf("retry budget exhausted, error %+v", attempt.Err)
+			return attempt.Err
+		}
+		ml.Infof("retry %+v after %s error %+v", i, delay, attempt.Err)
+		if delay > 0 {
+			timer := time.NewTimer(delay)
 			select {
-			case httpRes = <-resChan:
-				if _, err := parseRes(ctx, httpRes, res); err != nil {
-					ml.Errorf("parseRes error %+v", err)
-					return err
-				}
-				return nil
-			case err := <-errChan:
-				ml.Errorf("http error %+v", err)
-				return err
-			case <-time.After(currConfig.TotalTimeout):
-				ml.Errorf("req %+v error timeout", req)
-				return fmt.Errorf("req %+v error timeout", req)
+			case <-timer.C:
+			case <-ctx.Done():
+				timer.Stop()
+				ml.Errorf("req %s %s error %+v", method, path, ctx.Err())
+				return transportError(ctx.Err())
 			}
 		}
 	}
-	bodyStr := ""
-	if bodyByte != nil {
-		bodyStr = string(bodyByte)
+	return nil
+}
+
+// doAttempt sends one request bounded by timeout and parses its response
+func (s *httpClient) doAttempt(ctx context.Context, timeout time.Duration, method, url string, body *requestBody, res interface{}, header map[string]string, currConfig Config, attempt int) RetryAttempt {
+	ml := mklog.NewWithContext(ctx)
+	result := RetryAttempt{
+		Method:  method,
+		URL:     url,
+		Attempt: attempt,
+	}
+	attemptCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer func() {
+		// 流式返回时由body的Close取消
+		if cancel != nil {
+			cancel()
+		}
+	}()
+
+	req, err := http.NewRequestWithContext(attemptCtx, method, url, http.NoBody)
+	if err != nil {
+		ml.Errorf("http.NewRequestWithContext error %+v", err.Error())
+		result.Err = err
+		return result
 	}
-	ml.Errorf("req %+v error timeout", bodyStr)
-	return fmt.Errorf("req %+v error timeout", bodyStr)
+	if body != nil {
+		if req.Body, err = body.get(); err != nil {
+			ml.Errorf("body.get error %+v", err)
+			result.Err = err
+			return result
+		}
+		req.ContentLength = body.length
+		req.GetBody = body.get
+	}
+
+	// add http headers
+	req.Header.Set(common.HttpLogID, ml.GetLogID())
+	for k, v := range header {
+		req.Header.Add(k, v)
+	}
+
+	start := time.Now()
+	httpRes, err := s.client.Do(req)
+	callLog := ml.WithFields(map[string]interface{}{
+		common.LogFieldHttpMethod:  method,
+		common.LogFieldHttpURL:     url,
+		common.LogFieldHttpLatency: float64(time.Since(start).Microseconds()) / 1000,
+		common.LogFieldHttpAttempt: attempt,
+	})
+	if err != nil {
+		callLog.Errorf("client.Do error %+v", err)
+		result.Err = transportError(err)
+		return result
+	}
+	callLog.WithField(common.LogFieldHttpStatus, httpRes.StatusCode).Infof("http call finished")
+	if currConfig.stream != nil && httpRes.StatusCode/100 == 2 {
+		if currConfig.Response != nil {
+			currConfig.Response.StatusCode = httpRes.StatusCode
+			currConfig.Response.Status = httpRes.Status
+			currConfig.Response.Header = httpRes.Header
+		}
+		*currConfig.stream = &streamBody{ReadCloser: httpRes.Body, cancels: []context.CancelFunc{cancel}}
+		cancel = nil
+		result.StatusCode = httpRes.StatusCode
+		return result
+	}
+	defer httpRes.Body.Close()
+	result.StatusCode, result.Err = parseRes(ctx, httpRes, res, currConfig)
+	if result.StatusCode != 0 {
+		result.Header = httpRes.Header
+	}
+	return result
 }
 
-func parseRes(ctx context.Context, httpRes *http.Response, res interface{}) (int, error) {
+func parseRes(ctx context.Context, httpRes *http.Response, res interface{}, currConfig Config) (int, error) {
 	ml := mklog.NewWithContext(ctx)
+	if currConfig.Response != nil {
+		currConfig.Response.StatusCode = httpRes.StatusCode
+		currConfig.Response.Status = httpRes.Status
+		currConfig.Response.Header = httpRes.Header
+	}
+	if currConfig.ResponseWriter != nil && httpRes.StatusCode/100 == 2 {
+		return copyResponse(ctx, httpRes, currConfig.ResponseWriter, currConfig)
+	}
 	bodyByte, err := ioutil.ReadAll(httpRes.Body)
 	if err != nil {
 		ml.Errorf("ioutil.ReadAll error %+v", err)
-		return httpRes.StatusCode, err
+		return 0, transportError(err)
 	}
-	ml.Infof("response body: %+v", string(bodyByte))
-	err = json.Unmarshal(bodyByte, res)
-	if err != nil {
-		ml.Errorf("json.Unmarshal error %+v", err)
+	ml.Infof("response body: %s", logBody(bodyByte, int64(len(bodyByte)), currConfig.LogBodyLimit))
+	if currConfig.Response != nil {
+		currConfig.Response.Body = bodyByte
+	}
+	// 空body和204不解析
+	var decodeErr error
+	if res != nil && httpRes.StatusCode != http.StatusNoContent && len(bytes.TrimSpace(bodyByte)) > 0 {
+		if decodeErr = decodeJSON(bodyByte, res, currConfig.StrictDecode); decodeErr != nil {
+			ml.Errorf("json.Unmarshal error %+v", decodeErr)
+		}
+	}
+	if currConfig.DecodeError && currConfig.ErrorDecoder != nil {
+		if remoteErr := currConfig.ErrorDecoder.DecodeError(httpRes.StatusCode, bodyByte); remoteErr != nil {
+			return httpRes.StatusCode, &HTTPError{
+				StatusCode: httpRes.StatusCode,
+				Status:     httpRes.Status,
+				Err:        remoteErr,
+			}
+		}
 	}
 	if httpRes.StatusCode/100 != 2 {
-		return httpRes.StatusCode, fmt.Errorf("http error %d %s", httpRes.StatusCode, httpRes.Status)
+		return httpRes.StatusCode, &HTTPError{
+			StatusCode: httpRes.StatusCode,
+			Status:     httpRes.Status,
+		}
+	}
+	if decodeErr != nil && currConfig.FailOnDecodeError != nil && *currConfig.FailOnDecodeError {
+		return httpRes.StatusCode, mkerr.WithCategory(mkerr.Wrap(decodeErr, ErrNoDecode, "http response decode error"), mkerr.CategoryInternal)
 	}
 	return httpRes.StatusCode, nil
 }
+
+// decodeJSON unmarshals body into res, rejecting unknown fields when strict
+func decodeJSON(body []byte, res interface{}, strict bool) error {
+	if !strict {
+		return json.Unmarshal(body, res)
+	}
+	decoder := json.NewDecoder(bytes.NewReader(body))
+	decoder.DisallowUnknownFields()
+	return decoder.Decode(res)
+}
@@ -5,35 +5,85 @@ import (
 	"encoding/json"
 	"io/ioutil"
 	"net/http"
+	"net/http/httptest"
+	"runtime"
 	"strings"
+	"sync/atomic"
 	"testing"
 	"time"
+
+	"github.com/zhongxuqi/mklibs/mkerr"
 )
 
+func newTestClient(t *testing.T, host string, options ...HTTPClientOption) HTTPClient {
+	client, err := NewHTTPClient(host, options...)
+	if err != nil {
+		t.Fatalf("NewHTTPClient error %+v", err)
+	}
+	return client
+}
+
 func TestHttpOption(t *testing.T) {
-	if c, ok := NewHTTPClient("http://domain").(*httpClient); !ok {
+	if c, ok := newTestClient(t, "http://domain").(*httpClient); !ok {
 		t.Fatalf("NewHttpClient error")
 	} else {
-		currConfig := parseBaseConfig(defaultBaseConfig, c.options)
-		if c.host != "http://domain" || currConfig.MaxIdleConns != 10 || currConfig.IdleConnTimeout != time.Duration(30*time.Second) ||
+		currConfig, _ := parseBaseConfig(defaultBaseConfig, c.options)
+		if c.endpoints.hosts()[0] != "http://domain" || currConfig.MaxIdleConns != 10 || currConfig.IdleConnTimeout != time.Duration(30*time.Second) ||
 			currConfig.DisableCompression != true || currConfig.RetryTimeout != 5*time.Second || currConfig.RetryTimes != 0 ||
-			currConfig.TotalTimeout != 5*time.Second {
+			currConfig.TotalTimeout != 5*time.Second || currConfig.CallTimeout != 0 || currConfig.EjectFailures != 0 {
 			t.Fatalf("NewHttpClient data error %+v", c)
 		}
 	}
 
-	if c, ok := NewHTTPClient("http://domain", WithMaxIdleConns(100), WithIdleConnTimeout(time.Duration(300*time.Second)),
+	if c, ok := newTestClient(t, "http://domain", WithMaxIdleConns(100), WithIdleConnTimeout(time.Duration(300*time.Second)),
 		WithDisableCompression(false), WithRetryTimes(3), WithRetryTimeout(time.Duration(10*time.Second)),
 		WithTotalTimeout(time.Duration(15*time.Second))).(*httpClient); !ok {
 		t.Fatalf("NewHttpClient error")
 	} else {
-		currConfig := parseBaseConfig(defaultBaseConfig, c.options)
-		if c.host != "http://domain" || currConfig.MaxIdleConns != 100 || currConfig.IdleConnTimeout != time.Duration(300*time.Second) ||
+		currConfig, _ := parseBaseConfig(defaultBaseConfig, c.options)
+		if c.endpoints.hosts()[0] != "http://domain" || currConfig.MaxIdleConns != 100 || currConfig.IdleConnTimeout != time.Duration(300*time.Second) ||
 			currConfig.DisableCompression != false || currConfig.RetryTimeout != 10*time.Second || currConfig.RetryTimes != 3 ||
 			currConfig.TotalTimeout != 15*time.Second {
 			t.Fatalf("NewHttpClient data error %+v", c)
 		}
 	}
+
+	// 非法参数
+	if _, err := NewHTTPClient("http://domain", WithRetryTimes(-1)); err == nil {
+		t.Fatalf("NewHttpClient should fail")
+	}
+	if _, err := NewHTTPClient("http://domain", WithCallTimeout(-time.Second)); err == nil {
+		t.Fatalf("NewHttpClient should fail")
+	}
+	if _, err := NewHTTPClient("http://domain", WithErrorDecoder(nil)); err == nil {
+		t.Fatalf("NewHttpClient should fail")
+	}
+	func() {
+		defer func() {
+			if recover() == nil {
+				t.Fatalf("MustNewHTTPClient should panic")
+			}
+		}()
+		MustNewHTTPClient("http://domain", WithRetryTimes(-1))
+	}()
+	client := newTestClient(t, "http://domain")
+	if err := client.GetEx(context.TODO(), "/rpc", nil, nil, nil, WithTotalTimeout(0)); err == nil || !strings.Contains(err.Error(), "invalid TotalTimeout") {
+		t.Fatalf("client.GetEx should fail %+v", err)
+	}
+
+	// 自定义参数
+	withFastRetry := func(times int) HTTPClientOption {
+		return func(config *Config) error {
+			config.RetryTimes = times
+			config.RetryTimeout = 100 * time.Millisecond
+			return nil
+		}
+	}
+	if c, ok := newTestClient(t, "http://domain", withFastRetry(2)).(*httpClient); !ok {
+		t.Fatalf("NewHttpClient error")
+	} else if currConfig, _ := parseBaseConfig(defaultBaseConfig, c.options); currConfig.RetryTimes != 2 || currConfig.RetryTimeout != 100*time.Millisecond {
+		t.Fatalf("NewHttpClient data error %+v", currConfig)
+	}
 }
 
 type testRes struct {
@@ -68,7 +118,7 @@ func TestHttpRpc(t *testing.T) {
 	}()
 	defer server.Shutdown(context.TODO())
 
-	client := NewHTTPClient("http://127.0.0.1:8080")
+	client := newTestClient(t, "http://127.0.0.1:8080")
 
 	// 测试Get
 	var res testRes
@@ -127,7 +177,7 @@ func TestHttpRpcEx(t *testing.T) {
 	}()
 	defer server.Shutdown(context.TODO())
 
-	client := NewHTTPClient("http://127.0.0.1:8080")
+	client := newTestClient(t, "http://127.0.0.1:8080")
 
 	// 测试Get
 	var res testRes
@@ -158,7 +208,7 @@ type Param struct {
 }
 
 func TestHttpRpcRetry(t *testing.T) {
-	hasRPC := false
+	var hasRPC int32
 	mux := http.NewServeMux()
 	mux.HandleFunc("/rpc", func(w http.ResponseWriter, r *http.Request) {
 		var req Param
@@ -177,8 +227,7 @@ func TestHttpRpcRetry(t *testing.T) {
 			w.Write(b)
 			return
 		}
-		if !hasRPC {
-			hasRPC = true
+		if atomic.CompareAndSwapInt32(&hasRPC, 0, 1) {
 			time.Sleep(2 * time.Second)
 		}
 		b, _ := json.Marshal(testRes{
@@ -187,19 +236,10 @@ func TestHttpRpcRetry(t *testing.T) {
 		})
 		w.Write(b)
 	})
-	server := &http.Server{
-		Addr:           "127.0.0.1:8080",
-		Handler:        mux,
-		ReadTimeout:    10 * time.Second,
-		WriteTimeout:   10 * time.Second,
-		MaxHeaderBytes: 1 << 20,
-	}
-	go func() {
-		server.ListenAndServe()
-	}()
-	defer server.Shutdown(context.TODO())
+	server := httptest.NewServer(mux)
+	defer server.Close()
 
-	client := NewHTTPClient("http://127.0.0.1:8080", WithRetryTimeout(time.Second), WithTotalTimeout(time.Second))
+	client := newTestClient(t, server.URL, WithRetryTimeout(time.Second), WithTotalTimeout(time.Second))
 
 	// 测试Get
 	var res testRes
@@ -210,6 +250,14 @@ func TestHttpRpcRetry(t *testing.T) {
 	} else if res.ErrNo != 0 || res.ErrMsg != http.MethodPost {
 		t.Fatalf("client.Get data error %+v", res)
 	}
+
+	// CallTimeout不够重试时返回超时
+	atomic.StoreInt32(&hasRPC, 0)
+	if err := client.PostJSONEx(context.TODO(), "/rpc", Param{
+		Key: "value",
+	}, &res, map[string]string{}, WithRetryTimes(1), WithCallTimeout(500*time.Millisecond)); !mkerr.IsTimeout(err) {
+		t.Fatalf("client.Get error %+v", err)
+	}
 }
 
 func TestHttpErrorRetry(t *testing.T) {
@@ -240,7 +288,7 @@ func TestHttpErrorRetry(t *testing.T) {
 	defer server.Shutdown(context.TODO())
 
 	// 测试Get 成功 case1
-	client := NewHTTPClient("http://127.0.0.1:8080", WithRetryTimeout(time.Second), WithTotalTimeout(time.Second))
+	client := newTestClient(t, "http://127.0.0.1:8080", WithRetryTimeout(time.Second), WithTotalTimeout(time.Second))
 	var res testRes
 	if err := client.GetEx(context.TODO(), "/rpc", nil, &res, map[string]string{}, WithRetryTimes(1)); err == nil || !strings.Contains(err.Error(), "http error 404") {
 		t.Fatalf("client.Get error %+v", err)
@@ -248,7 +296,7 @@ func TestHttpErrorRetry(t *testing.T) {
 
 	// 测试Get 成功 case2
 	hasRPC = false
-	client = NewHTTPClient("http://127.0.0.1:8080", WithRetryTimeout(time.Second), WithTotalTimeout(time.Second), WithRetryHttpError(false))
+	client = newTestClient(t, "http://127.0.0.1:8080", WithRetryTimeout(time.Second), WithTotalTimeout(time.Second), WithRetryHttpError(false))
 	res = testRes{}
 	if err := client.GetEx(context.TODO(), "/rpc", nil, &res, map[string]string{}, WithRetryTimes(1)); err == nil || !strings.Contains(err.Error(), "http error 404") {
 		t.Fatalf("client.Get error %+v", err)
@@ -256,7 +304,7 @@ func TestHttpErrorRetry(t *testing.T) {
 
 	// 测试Get 成功
 	hasRPC = false
-	client = NewHTTPClient("http://127.0.0.1:8080", WithRetryTimeout(time.Second), WithTotalTimeout(time.Second), WithRetryHttpError(true))
+	client = newTestClient(t, "http://127.0.0.1:8080", WithRetryTimeout(time.Second), WithTotalTimeout(time.Second), WithRetryHttpError(true))
 	res = testRes{}
 	if err := client.GetEx(context.TODO(), "/rpc", nil, &res, map[string]string{}, WithRetryTimes(1)); err != nil {
 		t.Fatalf("client.Get error %+v", err)
@@ -264,3 +312,83 @@ func TestHttpErrorRetry(t *testing.T) {
 		t.Fatalf("client.Get data error %+v", res)
 	}
 }
+
+// checkGoroutines fails the test when goroutines started after it was called
+// are still running once the test ends
+func checkGoroutines(t *testing.T) {
+	before := runtime.NumGoroutine()
+	t.Cleanup(func() {
+		deadline := time.Now().Add(2 * time.Second)
+		for runtime.NumGoroutine() > before {
+			if time.Now().After(deadline) {
+				buf := make([]byte, 1<<16)
+				t.Fatalf("goroutines leaked: %d > %d\n%s", runtime.NumGoroutine(), before, buf[:runtime.Stack(buf, true)])
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+	})
+}
+
+func TestHttpContext(t *testing.T) {
+	checkGoroutines(t)
+	var count int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/flaky":
+			if atomic.AddInt32(&count, 1) == 1 {
+				select {
+				case <-r.Context().Done():
+				case <-time.After(time.Second):
+				}
+				return
+			}
+		case "/slow":
+			select {
+			case <-r.Context().Done():
+			case <-time.After(time.Second):
+			}
+			return
+		}
+		w.Write([]byte(`{"errno":0,"errmsg":"success"}`))
+	}))
+	defer server.Close()
+	client := newTestClient(t, server.URL, WithRetryTimeout(time.Second), WithTotalTimeout(time.Second))
+	defer client.(*httpClient).client.CloseIdleConnections()
+
+	// 取消请求
+	var res testRes
+	ctx, cancel := context.WithCancel(context.TODO())
+	time.AfterFunc(50*time.Millisecond, cancel)
+	start := time.Now()
+	if err := client.GetEx(ctx, "/slow", nil, &res, nil, WithRetryTimes(3)); !mkerr.IsCanceled(err) {
+		t.Fatalf("client.GetEx error %+v", err)
+	} else if time.Since(start) > 500*time.Millisecond {
+		t.Fatalf("client.GetEx not canceled in time %s", time.Since(start))
+	}
+
+	// 总超时
+	ctx, cancel = context.WithTimeout(context.TODO(), 100*time.Millisecond)
+	defer cancel()
+	start = time.Now()
+	if err := client.GetEx(ctx, "/slow", nil, &res, nil, WithRetryTimes(3)); !mkerr.IsTimeout(err) {
+		t.Fatalf("client.GetEx error %+v", err)
+	} else if time.Since(start) > 500*time.Millisecond {
+		t.Fatalf("client.GetEx deadline not honored %s", time.Since(start))
+	}
+
+	// CallTimeout限制包括重试在内的整个调用
+	start = time.Now()
+	if err := client.GetEx(context.TODO(), "/slow", nil, &res, nil, WithRetryTimes(5),
+		WithRetryTimeout(100*time.Millisecond), WithCallTimeout(250*time.Millisecond)); !mkerr.IsTimeout(err) {
+		t.Fatalf("client.GetEx error %+v", err)
+	} else if elapsed := time.Since(start); elapsed < 250*time.Millisecond || elapsed > 500*time.Millisecond {
+		t.Fatalf("client.GetEx CallTimeout not honored %s", elapsed)
+	}
+
+	// 单次请求超时后重试
+	if err := client.GetEx(context.TODO(), "/flaky", nil, &res, nil, WithRetryTimes(1), WithRetryTimeout(50*time.Millisecond)); err != nil {
+		t.Fatalf("client.GetEx error %+v", err)
+	} else if res.ErrMsg != "success" || atomic.LoadInt32(&count) != 2 {
+		t.Fatalf("client.GetEx data error %+v %d", res, count)
+	}
+}
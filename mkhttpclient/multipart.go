@@ -0,0 +1,240 @@
+package mkhttpclient
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/textproto"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/zhongxuqi/mklibs/mklog"
+)
+
+// ContentTypeOctetStream is the content type of the files added without one
+const ContentTypeOctetStream = "application/octet-stream"
+
+var quoteEscaper = strings.NewReplacer("\\", "\\\\", `"`, "\\\"")
+
+// multipartPart is a text field or a file of a MultipartForm
+type multipartPart struct {
+	field       string
+	fileName    string
+	contentType string
+	isFile      bool
+	value       []byte
+	path        string
+	reader      io.Reader
+}
+
+// MultipartForm builds a multipart/form-data body of text fields and files. The
+// body is streamed and generated again for each attempt, files added with
+// AddFilePath are opened on each attempt, readers implementing io.Seeker are
+// read again from their offset and other readers make the request not retried.
+type MultipartForm struct {
+	boundary string
+	parts    []multipartPart
+}
+
+// NewMultipartForm ...
+func NewMultipartForm() *MultipartForm {
+	return &MultipartForm{
+		boundary: multipart.NewWriter(nil).Boundary(),
+	}
+}
+
+// ContentType returns the Content-Type header of the form
+func (s *MultipartForm) ContentType() string {
+	return "multipart/form-data; boundary=" + s.boundary
+}
+
+// AddField adds the text field name
+func (s *MultipartForm) AddField(name, value string) *MultipartForm {
+	s.parts = append(s.parts, multipartPart{field: name, value: []byte(value)})
+	return s
+}
+
+// AddFile adds a file of field with content, the content type defaults to
+// application/octet-stream. A field may hold several files.
+func (s *MultipartForm) AddFile(field, fileName, contentType string, content []byte) *MultipartForm {
+	s.parts = append(s.parts, multipartPart{field: field, fileName: fileName, contentType: contentType, isFile: true, value: content})
+	return s
+}
+
+// AddFilePath adds the file at path to field, named after its base name
+func (s *MultipartForm) AddFilePath(field, path, contentType string) *MultipartForm {
+	s.parts = append(s.parts, multipartPart{field: field, fileName: filepath.Base(path), contentType: contentType, isFile: true, path: path})
+	return s
+}
+
+// AddFileReader adds a file of field read from r
+func (s *MultipartForm) AddFileReader(field, fileName, contentType string, r io.Reader) *MultipartForm {
+	s.parts = append(s.parts, multipartPart{field: field, fileName: fileName, contentType: contentType, isFile: true, reader: r})
+	return s
+}
+
+// body returns the request body generating the form
+func (s *MultipartForm) body() *requestBody {
+	res := &requestBody{}
+	offsets := make(map[int]int64)
+	for i, part := range s.parts {
+		if part.reader == nil {
+			continue
+		}
+		seeker, ok := part.reader.(io.Seeker)
+		if !ok {
+			res.oneShot = true
+			continue
+		}
+		offset, err := seeker.Seek(0, io.SeekCurrent)
+		if err != nil {
+			res.oneShot = true
+			continue
+		}
+		offsets[i] = offset
+	}
+	length, lengthErr := s.length(offsets)
+	res.length = length
+	guard := &bodyGuard{}
+	used := false
+	res.get = func() (io.ReadCloser, error) {
+		if res.oneShot && used {
+			return nil, errBodyNotReplayable
+		}
+		used = true
+		if lengthErr != nil {
+			return nil, lengthErr
+		}
+		// 等上次请求的写协程结束后再重置读取位置
+		guard.wait()
+		for i, offset := range offsets {
+			if _, err := s.parts[i].reader.(io.Seeker).Seek(offset, io.SeekStart); err != nil {
+				return nil, err
+			}
+		}
+		r, w := io.Pipe()
+		done := make(chan struct{})
+		guard.done = done
+		go func() {
+			w.CloseWithError(s.writeTo(w))
+			close(done)
+		}()
+		return r, nil
+	}
+	return res
+}
+
+// length returns the size of the form, -1 when a reader has an unknown size. It
+// fails when a file of AddFilePath can not be read.
+func (s *MultipartForm) length(offsets map[int]int64) (int64, error) {
+	counter := &prefixWriter{}
+	writer := multipart.NewWriter(counter)
+	if err := writer.SetBoundary(s.boundary); err != nil {
+		return 0, err
+	}
+	size := int64(0)
+	for i, part := range s.parts {
+		if _, err := writer.CreatePart(part.header()); err != nil {
+			return 0, err
+		}
+		switch {
+		case part.path != "":
+			info, err := os.Stat(part.path)
+			if err != nil {
+				return 0, err
+			}
+			size += info.Size()
+		case part.reader != nil:
+			offset, ok := offsets[i]
+			if !ok {
+				return -1, nil
+			}
+			end, err := part.reader.(io.Seeker).Seek(0, io.SeekEnd)
+			if err != nil {
+				return -1, nil
+			}
+			if _, err := part.reader.(io.Seeker).Seek(offset, io.SeekStart); err != nil {
+				return 0, err
+			}
+			size += end - offset
+		default:
+			size += int64(len(part.value))
+		}
+	}
+	if err := writer.Close(); err != nil {
+		return 0, err
+	}
+	return counter.n + size, nil
+}
+
+// writeTo writes the form to w
+func (s *MultipartForm) writeTo(w io.Writer) error {
+	writer := multipart.NewWriter(w)
+	if err := writer.SetBoundary(s.boundary); err != nil {
+		return err
+	}
+	for _, part := range s.parts {
+		partWriter, err := writer.CreatePart(part.header())
+		if err != nil {
+			return err
+		}
+		if err := part.writeTo(partWriter); err != nil {
+			return err
+		}
+	}
+	return writer.Close()
+}
+
+// header returns the MIME header of the part
+func (s multipartPart) header() textproto.MIMEHeader {
+	header := make(textproto.MIMEHeader)
+	if !s.isFile {
+		header.Set("Content-Disposition", fmt.Sprintf(`form-data; name="%s"`, quoteEscaper.Replace(s.field)))
+		return header
+	}
+	header.Set("Content-Disposition", fmt.Sprintf(`form-data; name="%s"; filename="%s"`,
+		quoteEscaper.Replace(s.field), quoteEscaper.Replace(s.fileName)))
+	contentType := s.contentType
+	if contentType == "" {
+		contentType = ContentTypeOctetStream
+	}
+	header.Set("Content-Type", contentType)
+	return header
+}
+
+// writeTo writes the content of the part to w
+func (s multipartPart) writeTo(w io.Writer) error {
+	switch {
+	case s.path != "":
+		file, err := os.Open(s.path)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		_, err = io.Copy(w, file)
+		return err
+	case s.reader != nil:
+		_, err := io.Copy(w, s.reader)
+		return err
+	}
+	_, err := io.Copy(w, bytes.NewReader(s.value))
+	return err
+}
+
+// PostMultipartEx posts form as a multipart/form-data body
+func (s *httpClient) PostMultipartEx(ctx context.Context, path string, form *MultipartForm, res interface{}, header map[string]string, options ...HTTPClientOption) error {
+	ml := mklog.NewWithContext(ctx)
+	ml.Infof("url [%s]%s%s header %+v", http.MethodPost, s.host(), path, header)
+	if form == nil {
+		form = NewMultipartForm()
+	}
+	if header == nil {
+		header = make(map[string]string)
+	}
+	header["Content-Type"] = form.ContentType()
+	return s.do(ctx, http.MethodPost, path, form.body(), res, header, options...)
+}
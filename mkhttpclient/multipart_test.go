@@ -0,0 +1,134 @@
+package mkhttpclient
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+type multipartRes struct {
+	ContentLength int64               `json:"content_length"`
+	Fields        map[string][]string `json:"fields"`
+	Files         []string            `json:"files"`
+}
+
+func TestHttpMultipart(t *testing.T) {
+	checkGoroutines(t)
+	var count int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := r.ParseMultipartForm(1 << 20); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if r.URL.Path == "/flaky" && atomic.AddInt32(&count, 1) == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		res := multipartRes{ContentLength: r.ContentLength, Fields: r.MultipartForm.Value}
+		for field, headers := range r.MultipartForm.File {
+			for _, header := range headers {
+				file, _ := header.Open()
+				content, _ := ioutil.ReadAll(file)
+				file.Close()
+				res.Files = append(res.Files, field+"|"+header.Filename+"|"+header.Header.Get("Content-Type")+"|"+string(content))
+			}
+		}
+		sort.Strings(res.Files)
+		b, _ := json.Marshal(res)
+		w.Write(b)
+	}))
+	defer server.Close()
+	client := newTestClient(t, server.URL)
+	defer client.(*httpClient).client.CloseIdleConnections()
+
+	// 旧接口上传多个文件
+	var res multipartRes
+	if err := client.PostFileEx(context.TODO(), "/", map[string][]byte{"a.txt": []byte("a"), "b.txt": []byte("b")}, &res, nil); err != nil {
+		t.Fatalf("client.PostFileEx error %+v", err)
+	}
+	if strings.Join(res.Files, ",") != "a.txt|a.txt|application/octet-stream|a,b.txt|b.txt|application/octet-stream|b" {
+		t.Fatalf("client.PostFileEx files %+v", res.Files)
+	}
+
+	path := filepath.Join(t.TempDir(), "photo.png")
+	os.WriteFile(path, []byte("png"), 0644)
+	form := NewMultipartForm().
+		AddField("title", "holiday").
+		AddField("tag", "sea").
+		AddField("tag", "sun").
+		AddFile("photos", `my "best".jpg`, "image/jpeg", []byte("jpg")).
+		AddFilePath("photos", path, "image/png").
+		AddFileReader("notes", "notes.txt", "text/plain", strings.NewReader("notes"))
+	res = multipartRes{}
+	if err := client.PostMultipartEx(context.TODO(), "/flaky", form, &res, nil, WithRetryTimes(1), WithRetryHttpError(true)); err != nil {
+		t.Fatalf("client.PostMultipartEx error %+v", err)
+	}
+	if atomic.LoadInt32(&count) != 2 || res.ContentLength <= 0 || res.Fields["title"][0] != "holiday" || strings.Join(res.Fields["tag"], ",") != "sea,sun" {
+		t.Fatalf("client.PostMultipartEx count %d res %+v", count, res)
+	}
+	if strings.Join(res.Files, ",") != `notes|notes.txt|text/plain|notes,photos|my "best".jpg|image/jpeg|jpg,photos|photo.png|image/png|png` {
+		t.Fatalf("client.PostMultipartEx files %+v", res.Files)
+	}
+
+	// 不可重放的reader只发送一次, 长度未知
+	atomic.StoreInt32(&count, 0)
+	form = NewMultipartForm().AddFileReader("notes", "notes.txt", "", ioutil.NopCloser(strings.NewReader("notes")))
+	if err := client.PostMultipartEx(context.TODO(), "/flaky", form, &res, nil, WithRetryTimes(1), WithRetryHttpError(true)); StatusCode(err) != http.StatusServiceUnavailable {
+		t.Fatalf("client.PostMultipartEx error %+v", err)
+	}
+	res = multipartRes{}
+	if err := client.PostMultipartEx(context.TODO(), "/", NewMultipartForm().AddFileReader("notes", "notes.txt", "", ioutil.NopCloser(strings.NewReader("notes"))), &res, nil); err != nil {
+		t.Fatalf("client.PostMultipartEx error %+v", err)
+	} else if res.ContentLength != -1 || len(res.Files) != 1 {
+		t.Fatalf("client.PostMultipartEx res %+v", res)
+	}
+
+	form = NewMultipartForm().AddFilePath("file", filepath.Join(t.TempDir(), "missing"), "")
+	if err := client.PostMultipartEx(context.TODO(), "/", form, &res, nil, WithRetryTimes(3)); !os.IsNotExist(err) {
+		t.Fatalf("client.PostMultipartEx error %+v", err)
+	}
+}
+
+func TestHttpMultipartRetry(t *testing.T) {
+	var count int32
+	payload := bytes.Repeat([]byte("0123456789abcdef"), 512*1024)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// 重试时传输层仍在发送上次请求的body
+		if atomic.AddInt32(&count, 1) < 4 {
+			respondUnavailableEarly(w, r)
+			return
+		}
+		file, _, err := r.FormFile("file")
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		defer file.Close()
+		if content, _ := ioutil.ReadAll(file); !bytes.Equal(content, payload) || r.FormValue("name") != "mk" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		w.Write([]byte(`{"errno":0,"errmsg":"success"}`))
+	}))
+	defer server.Close()
+	client := newTestClient(t, server.URL, WithRetryTimes(3), WithRetryHttpError(true))
+	defer client.(*httpClient).client.CloseIdleConnections()
+
+	form := NewMultipartForm().AddField("name", "mk").AddFileReader("file", "payload.bin", "", bytes.NewReader(payload))
+	var res testRes
+	if err := client.PostMultipartEx(context.TODO(), "/", form, &res, nil); err != nil {
+		t.Fatalf("client.PostMultipartEx error %+v", err)
+	}
+	if res.ErrMsg != "success" || atomic.LoadInt32(&count) != 4 {
+		t.Fatalf("client.PostMultipartEx data error %+v %d", res, count)
+	}
+}
@@ -1,144 +1,323 @@
 package mkhttpclient
 
-import "time"
-
-// OptionKey ...
-type OptionKey int
-
-// const ...
-const (
-	OptionKeyMaxIdleConns OptionKey = iota
-	OptionKeyIdleConnTimeout
-	OptionKeyDisableCompression
-	OptionKeyRetryTimes
-	OptionKeyRetryTimeout
-	OptionKeyTotalTimeout
-	OptionKeyRetryHttpError
+import (
+	"fmt"
+	"io"
+	"net/url"
+	"time"
 )
 
-// HTTPClientOption ...
-type HTTPClientOption interface {
-	OptionKey() OptionKey
-	OptionValue() interface{}
-}
-
-// httpClientOption ...
-type httpClientOption struct {
-	optionKey   OptionKey
-	optionValue interface{}
-}
-
-// OptionKey ...
-func (s *httpClientOption) OptionKey() OptionKey {
-	return s.optionKey
-}
-
-// OptionValue ...
-func (s *httpClientOption) OptionValue() interface{} {
-	return s.optionValue
-}
-
-// baseConfig ...
-type baseConfig struct {
+// Config is the configuration an HTTPClient and each of its calls are made with.
+// Options of other packages may set its fields too.
+type Config struct {
 	MaxIdleConns       int
 	IdleConnTimeout    time.Duration
 	DisableCompression bool
 	RetryTimes         int
 	RetryTimeout       time.Duration
 	TotalTimeout       time.Duration
+	CallTimeout        time.Duration
 	RetryHttpError     bool
+	DecodeError        bool
+	ErrorDecoder       ErrorDecoder
+	RetryPolicy        RetryPolicy
+	RetryBudget        *RetryBudget
+	CircuitBreaker     *CircuitBreaker
+	HostBreakers       *HostCircuitBreakers
+	Balancer           Balancer
+	BalanceKey         string
+	EjectFailures      int
+	EjectDuration      time.Duration
+	StrictDecode       bool
+	FailOnDecodeError  *bool // nil leaves the default of the call, false except for the typed helpers
+	Query              url.Values
+	Response           *Response
+	ResponseWriter     io.Writer
+	LogBodyLimit       int
+
+	stream *io.ReadCloser // set by Stream
 }
 
+// HTTPClientOption sets a field of the config, it returns an error for an invalid value
+type HTTPClientOption func(config *Config) error
+
 // parseBaseConfig ...
-func parseBaseConfig(defaultOption baseConfig, options []HTTPClientOption) baseConfig {
+func parseBaseConfig(defaultOption Config, options []HTTPClientOption) (Config, error) {
 	res := defaultOption
 	for _, option := range options {
-		switch option.OptionKey() {
-		case OptionKeyMaxIdleConns:
-			if v, ok := option.OptionValue().(int); ok {
-				res.MaxIdleConns = v
-			}
-		case OptionKeyIdleConnTimeout:
-			if v, ok := option.OptionValue().(time.Duration); ok {
-				res.IdleConnTimeout = v
-			}
-		case OptionKeyDisableCompression:
-			if v, ok := option.OptionValue().(bool); ok {
-				res.DisableCompression = v
-			}
-		case OptionKeyRetryTimes:
-			if v, ok := option.OptionValue().(int); ok {
-				res.RetryTimes = v
-			}
-		case OptionKeyRetryTimeout:
-			if v, ok := option.OptionValue().(time.Duration); ok {
-				res.RetryTimeout = v
-			}
-		case OptionKeyTotalTimeout:
-			if v, ok := option.OptionValue().(time.Duration); ok {
-				res.TotalTimeout = v
-			}
-		case OptionKeyRetryHttpError:
-			if v, ok := option.OptionValue().(bool); ok {
-				res.RetryHttpError = v
-			}
-		}
-	}
-	return res
+		if option == nil {
+			continue
+		}
+		if err := option(&res); err != nil {
+			return res, err
+		}
+	}
+	return res, nil
 }
 
 // WithMaxIdleConns ...
 func WithMaxIdleConns(maxIdleConns int) HTTPClientOption {
-	return &httpClientOption{
-		optionKey:   OptionKeyMaxIdleConns,
-		optionValue: maxIdleConns,
+	return func(config *Config) error {
+		if maxIdleConns < 0 {
+			return fmt.Errorf("invalid MaxIdleConns %d", maxIdleConns)
+		}
+		config.MaxIdleConns = maxIdleConns
+		return nil
 	}
 }
 
 // WithIdleConnTimeout ...
 func WithIdleConnTimeout(idleConnTimeout time.Duration) HTTPClientOption {
-	return &httpClientOption{
-		optionKey:   OptionKeyIdleConnTimeout,
-		optionValue: idleConnTimeout,
+	return func(config *Config) error {
+		if idleConnTimeout < 0 {
+			return fmt.Errorf("invalid IdleConnTimeout %s", idleConnTimeout)
+		}
+		config.IdleConnTimeout = idleConnTimeout
+		return nil
 	}
 }
 
 // WithDisableCompression ...
 func WithDisableCompression(disableCompression bool) HTTPClientOption {
-	return &httpClientOption{
-		optionKey:   OptionKeyDisableCompression,
-		optionValue: disableCompression,
+	return func(config *Config) error {
+		config.DisableCompression = disableCompression
+		return nil
 	}
 }
 
 // WithRetryTimes ...
 func WithRetryTimes(retryTimes int) HTTPClientOption {
-	return &httpClientOption{
-		optionKey:   OptionKeyRetryTimes,
-		optionValue: retryTimes,
+	return func(config *Config) error {
+		if retryTimes < 0 {
+			return fmt.Errorf("invalid RetryTimes %d", retryTimes)
+		}
+		config.RetryTimes = retryTimes
+		return nil
 	}
 }
 
 // WithRetryTimeout ...
 func WithRetryTimeout(timeout time.Duration) HTTPClientOption {
-	return &httpClientOption{
-		optionKey:   OptionKeyRetryTimeout,
-		optionValue: timeout,
+	return func(config *Config) error {
+		if timeout <= 0 {
+			return fmt.Errorf("invalid RetryTimeout %s", timeout)
+		}
+		config.RetryTimeout = timeout
+		return nil
 	}
 }
 
-// WithTotalTimeout ...
+// WithTotalTimeout sets the timeout of the last attempt, the previous attempts use
+// RetryTimeout. See WithCallTimeout to bound the whole call.
 func WithTotalTimeout(timeout time.Duration) HTTPClientOption {
-	return &httpClientOption{
-		optionKey:   OptionKeyTotalTimeout,
-		optionValue: timeout,
+	return func(config *Config) error {
+		if timeout <= 0 {
+			return fmt.Errorf("invalid TotalTimeout %s", timeout)
+		}
+		config.TotalTimeout = timeout
+		return nil
+	}
+}
+
+// WithCallTimeout bounds the whole call, the attempts and the delays between
+// them, 0 leaves it bounded by the context of the call only. It is 0 by default.
+func WithCallTimeout(timeout time.Duration) HTTPClientOption {
+	return func(config *Config) error {
+		if timeout < 0 {
+			return fmt.Errorf("invalid CallTimeout %s", timeout)
+		}
+		config.CallTimeout = timeout
+		return nil
 	}
 }
 
 // WithRetryHttpError
 func WithRetryHttpError(enable bool) HTTPClientOption {
-	return &httpClientOption{
-		optionKey:   OptionKeyRetryHttpError,
-		optionValue: enable,
+	return func(config *Config) error {
+		config.RetryHttpError = enable
+		return nil
+	}
+}
+
+// WithDecodeError decodes the errno and errmsg fields of response bodies into an
+// mkerr.Error held by the returned HTTPError, including 2xx responses with a
+// non-zero errno
+func WithDecodeError(enable bool) HTTPClientOption {
+	return func(config *Config) error {
+		config.DecodeError = enable
+		return nil
+	}
+}
+
+// WithErrorFields enables error decoding reading the errno and message from the given fields
+func WithErrorFields(errNoField, errMsgField string) HTTPClientOption {
+	return func(config *Config) error {
+		if errNoField == "" {
+			return fmt.Errorf("empty errno field")
+		}
+		return WithErrorDecoder(NewFieldErrorDecoder(errNoField, errMsgField))(config)
+	}
+}
+
+// WithErrorDecoder enables error decoding with decoder
+func WithErrorDecoder(decoder ErrorDecoder) HTTPClientOption {
+	return func(config *Config) error {
+		if decoder == nil {
+			return fmt.Errorf("nil ErrorDecoder")
+		}
+		config.DecodeError = true
+		config.ErrorDecoder = decoder
+		return nil
+	}
+}
+
+// WithRetryPolicy decides the retries with policy instead of RetryHttpError
+func WithRetryPolicy(policy RetryPolicy) HTTPClientOption {
+	return func(config *Config) error {
+		if policy == nil {
+			return fmt.Errorf("nil RetryPolicy")
+		}
+		config.RetryPolicy = policy
+		return nil
+	}
+}
+
+// WithRetryBudget limits the retries with budget, share it between the calls to a downstream
+func WithRetryBudget(budget *RetryBudget) HTTPClientOption {
+	return func(config *Config) error {
+		if budget == nil {
+			return fmt.Errorf("nil RetryBudget")
+		}
+		config.RetryBudget = budget
+		return nil
+	}
+}
+
+// WithCircuitBreaker guards the calls with breaker, share it between the calls to a downstream
+func WithCircuitBreaker(breaker *CircuitBreaker) HTTPClientOption {
+	return func(config *Config) error {
+		if breaker == nil {
+			return fmt.Errorf("nil CircuitBreaker")
+		}
+		config.CircuitBreaker = breaker
+		return nil
+	}
+}
+
+// WithHostCircuitBreakers guards the calls to each host with its own breaker of breakers
+func WithHostCircuitBreakers(breakers *HostCircuitBreakers) HTTPClientOption {
+	return func(config *Config) error {
+		if breakers == nil {
+			return fmt.Errorf("nil HostCircuitBreakers")
+		}
+		config.HostBreakers = breakers
+		return nil
+	}
+}
+
+// WithBalancer picks the endpoint of each request with balancer, round robin by default
+func WithBalancer(balancer Balancer) HTTPClientOption {
+	return func(config *Config) error {
+		if balancer == nil {
+			return fmt.Errorf("nil Balancer")
+		}
+		config.Balancer = balancer
+		return nil
+	}
+}
+
+// WithBalanceKey gives the key of the call to the balancer, see NewConsistentHashBalancer
+func WithBalanceKey(key string) HTTPClientOption {
+	return func(config *Config) error {
+		config.BalanceKey = key
+		return nil
+	}
+}
+
+// WithEjection ejects an endpoint for duration after failures consecutive failures, 0 failures disables ejection.
+// Ejection is disabled by default.
+func WithEjection(failures int, duration time.Duration) HTTPClientOption {
+	return func(config *Config) error {
+		if failures < 0 {
+			return fmt.Errorf("invalid EjectFailures %d", failures)
+		}
+		if failures > 0 && duration <= 0 {
+			return fmt.Errorf("invalid EjectDuration %s", duration)
+		}
+		config.EjectFailures = failures
+		config.EjectDuration = duration
+		return nil
+	}
+}
+
+// WithStrictDecode rejects response bodies holding fields unknown to res
+func WithStrictDecode(enable bool) HTTPClientOption {
+	return func(config *Config) error {
+		config.StrictDecode = enable
+		return nil
+	}
+}
+
+// WithFailOnDecodeError returns the errors decoding response bodies instead of only logging them
+func WithFailOnDecodeError(enable bool) HTTPClientOption {
+	return func(config *Config) error {
+		config.FailOnDecodeError = &enable
+		return nil
+	}
+}
+
+// WithQuery adds params, encoded with EncodeQuery, to the query of the request
+func WithQuery(params interface{}) HTTPClientOption {
+	return func(config *Config) error {
+		values, err := EncodeQuery(params)
+		if err != nil {
+			return err
+		}
+		query := make(url.Values, len(config.Query)+len(values))
+		for k, vs := range config.Query {
+			query[k] = append(query[k], vs...)
+		}
+		for k, vs := range values {
+			query[k] = append(query[k], vs...)
+		}
+		config.Query = query
+		return nil
+	}
+}
+
+// WithResponse fills res with the response of the call. It is a call option,
+// clients created with it fail as their concurrent calls would share res.
+func WithResponse(res *Response) HTTPClientOption {
+	return func(config *Config) error {
+		if res == nil {
+			return fmt.Errorf("nil Response")
+		}
+		config.Response = res
+		return nil
+	}
+}
+
+// WithResponseWriter streams the body of 2xx responses to w instead of decoding it. A
+// call failing after writing to w is not retried. Writing the body is bounded by the
+// timeout of its attempt, TotalTimeout when it is the last one, 5s by default, and by
+// CallTimeout and ctx: raise them for large downloads.
+func WithResponseWriter(w io.Writer) HTTPClientOption {
+	return func(config *Config) error {
+		if w == nil {
+			return fmt.Errorf("nil ResponseWriter")
+		}
+		config.ResponseWriter = w
+		return nil
+	}
+}
+
+// WithLogBodyLimit logs at most limit bytes of the response bodies, 0 logs none
+func WithLogBodyLimit(limit int) HTTPClientOption {
+	return func(config *Config) error {
+		if limit < 0 {
+			return fmt.Errorf("invalid LogBodyLimit %d", limit)
+		}
+		config.LogBodyLimit = limit
+		return nil
 	}
 }
@@ -0,0 +1,230 @@
+package mkhttpclient
+
+import (
+	"encoding"
+	"fmt"
+	"net/url"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	timeType          = reflect.TypeOf(time.Time{})
+	textMarshalerType = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+)
+
+// EncodeQuery encodes params into query values. params may be url.Values, a map
+// with string keys or a struct. Slices and arrays give repeated keys, nil
+// pointers are omitted, times are formatted with time.RFC3339 and values
+// implementing encoding.TextMarshaler are encoded with it.
+//
+// Struct fields are encoded under the name of their url tag, or their field
+// name, and are skipped with the tag "-". The tag options are omitempty, unix
+// and unixmilli, the layout tag formats a time:
+//
+//	type Filter struct {
+//		Name  string    `url:"name,omitempty"`
+//		IDs   []int64   `url:"id"`
+//		Since time.Time `url:"since" layout:"2006-01-02"`
+//		Until time.Time `url:"until,unix"`
+//	}
+func EncodeQuery(params interface{}) (url.Values, error) {
+	res := make(url.Values)
+	if params == nil {
+		return res, nil
+	}
+	if values, ok := params.(url.Values); ok {
+		for k, vs := range values {
+			res[k] = append([]string(nil), vs...)
+		}
+		return res, nil
+	}
+	v := reflect.ValueOf(params)
+	for v.Kind() == reflect.Ptr {
+		if v.IsNil() {
+			return res, nil
+		}
+		v = v.Elem()
+	}
+	switch v.Kind() {
+	case reflect.Map:
+		if v.Type().Key().Kind() != reflect.String {
+			return nil, fmt.Errorf("query params map key must be string, got %s", v.Type().Key())
+		}
+		iter := v.MapRange()
+		for iter.Next() {
+			if err := encodeQueryValue(res, iter.Key().String(), iter.Value(), queryTag{}); err != nil {
+				return nil, err
+			}
+		}
+	case reflect.Struct:
+		if err := encodeQueryStruct(res, v); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("unsupported query params type %s", v.Type())
+	}
+	return res, nil
+}
+
+// queryTag is the parsed url and layout tags of a struct field
+type queryTag struct {
+	omitEmpty bool
+	unix      bool
+	unixMilli bool
+	layout    string
+}
+
+func encodeQueryStruct(values url.Values, v reflect.Value) error {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		tag, hasTag := field.Tag.Lookup("url")
+		if tag == "-" {
+			continue
+		}
+		name, options, _ := strings.Cut(tag, ",")
+		fieldValue := v.Field(i)
+		if field.Anonymous && name == "" {
+			for fieldValue.Kind() == reflect.Ptr && !fieldValue.IsNil() {
+				fieldValue = fieldValue.Elem()
+			}
+			if fieldValue.Kind() == reflect.Struct && fieldValue.Type() != timeType {
+				if err := encodeQueryStruct(values, fieldValue); err != nil {
+					return err
+				}
+				continue
+			}
+		}
+		if !field.IsExported() {
+			continue
+		}
+		if !hasTag || name == "" {
+			name = field.Name
+		}
+		parsed := queryTag{layout: field.Tag.Get("layout")}
+		for _, option := range strings.Split(options, ",") {
+			switch option {
+			case "omitempty":
+				parsed.omitEmpty = true
+			case "unix":
+				parsed.unix = true
+			case "unixmilli":
+				parsed.unixMilli = true
+			}
+		}
+		if parsed.omitEmpty && fieldValue.IsZero() {
+			continue
+		}
+		if err := encodeQueryValue(values, name, fieldValue, parsed); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func encodeQueryValue(values url.Values, name string, v reflect.Value, tag queryTag) error {
+	for v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			return nil
+		}
+		v = v.Elem()
+	}
+	if v.Kind() == reflect.Slice || v.Kind() == reflect.Array {
+		if v.Type().Elem().Kind() != reflect.Uint8 {
+			for i := 0; i < v.Len(); i++ {
+				if err := encodeQueryValue(values, name, v.Index(i), tag); err != nil {
+					return err
+				}
+			}
+			return nil
+		}
+	}
+	s, err := formatQueryValue(v, tag)
+	if err != nil {
+		return err
+	}
+	values.Add(name, s)
+	return nil
+}
+
+func formatQueryValue(v reflect.Value, tag queryTag) (string, error) {
+	if v.Type() == timeType && v.CanInterface() {
+		t := v.Interface().(time.Time)
+		switch {
+		case tag.unix:
+			return strconv.FormatInt(t.Unix(), 10), nil
+		case tag.unixMilli:
+			return strconv.FormatInt(t.UnixMilli(), 10), nil
+		case tag.layout != "":
+			return t.Format(tag.layout), nil
+		}
+		return t.Format(time.RFC3339), nil
+	}
+	if v.Type().Implements(textMarshalerType) && v.CanInterface() {
+		b, err := v.Interface().(encoding.TextMarshaler).MarshalText()
+		return string(b), err
+	}
+	if reflect.PointerTo(v.Type()).Implements(textMarshalerType) && v.CanInterface() {
+		// MarshalText有指针接收者, 不可寻址时使用副本
+		ptr := reflect.New(v.Type())
+		if v.CanAddr() {
+			ptr = v.Addr()
+		} else {
+			ptr.Elem().Set(v)
+		}
+		b, err := ptr.Interface().(encoding.TextMarshaler).MarshalText()
+		return string(b), err
+	}
+	switch v.Kind() {
+	case reflect.String:
+		return v.String(), nil
+	case reflect.Bool:
+		return strconv.FormatBool(v.Bool()), nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return strconv.FormatInt(v.Int(), 10), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return strconv.FormatUint(v.Uint(), 10), nil
+	case reflect.Float32, reflect.Float64:
+		return strconv.FormatFloat(v.Float(), 'f', -1, v.Type().Bits()), nil
+	case reflect.Slice:
+		return string(v.Bytes()), nil
+	case reflect.Array:
+		// 不可寻址的数组不能调用Bytes
+		b := make([]byte, v.Len())
+		reflect.Copy(reflect.ValueOf(b), v)
+		return string(b), nil
+	}
+	return fmt.Sprintf("%+v", v), nil
+}
+
+// appendQuery merges values into the query of path, keeping the parameters
+// already in it
+func appendQuery(path string, values url.Values) (string, error) {
+	if len(values) == 0 {
+		return path, nil
+	}
+	u, err := url.Parse(path)
+	if err != nil {
+		return "", err
+	}
+	query := u.Query()
+	for k, vs := range values {
+		for _, v := range vs {
+			query.Add(k, v)
+		}
+	}
+	u.RawQuery = query.Encode()
+	return u.String(), nil
+}
+
+// pathWithQuery merges the encoded params into the query of path
+func pathWithQuery(path string, params interface{}) (string, error) {
+	values, err := EncodeQuery(params)
+	if err != nil {
+		return "", err
+	}
+	return appendQuery(path, values)
+}
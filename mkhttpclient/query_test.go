@@ -0,0 +1,125 @@
+package mkhttpclient
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+)
+
+type queryPage struct {
+	Page int `url:"page,omitempty"`
+	Size int `url:"size"`
+}
+
+type queryFilter struct {
+	queryPage
+	Name    string    `url:"name,omitempty"`
+	IDs     []int64   `url:"id"`
+	Tags    [2]string `url:"tag"`
+	Since   time.Time `url:"since" layout:"2006-01-02"`
+	Until   time.Time `url:"until,unix"`
+	Created time.Time
+	Owner   *string `url:"owner"`
+	Ignored string  `url:"-"`
+	private string
+}
+
+// queryLevel implements encoding.TextMarshaler with a pointer receiver
+type queryLevel int
+
+func (s *queryLevel) MarshalText() ([]byte, error) {
+	return []byte(fmt.Sprintf("L%d", int(*s))), nil
+}
+
+type queryRaw struct {
+	Code  [3]byte    `url:"code"`
+	Level queryLevel `url:"level"`
+}
+
+func TestEncodeQuery(t *testing.T) {
+	day := time.Date(2022, 3, 4, 5, 6, 7, 0, time.UTC)
+	values, err := EncodeQuery(queryFilter{
+		queryPage: queryPage{Size: 20},
+		IDs:       []int64{1, 2},
+		Tags:      [2]string{"a b", "c&d"},
+		Since:     day,
+		Until:     day,
+		Created:   day,
+		Ignored:   "ignored",
+		private:   "private",
+	})
+	if err != nil {
+		t.Fatalf("EncodeQuery error %+v", err)
+	}
+	if encoded := values.Encode(); encoded != "Created=2022-03-04T05%3A06%3A07Z&id=1&id=2&since=2022-03-04&size=20&tag=a+b&tag=c%26d&until=1646370367" {
+		t.Fatalf("EncodeQuery %s", encoded)
+	}
+
+	values, err = EncodeQuery(map[string]interface{}{"q": "x y", "ids": []string{"1", "2"}, "on": true, "rate": 0.5, "none": nil})
+	if err != nil {
+		t.Fatalf("EncodeQuery error %+v", err)
+	}
+	if encoded := values.Encode(); encoded != "ids=1&ids=2&on=true&q=x+y&rate=0.5" {
+		t.Fatalf("EncodeQuery %s", encoded)
+	}
+	// 字节数组和指针接收者的TextMarshaler, 字段可寻址或不可寻址
+	for _, params := range []interface{}{queryRaw{Code: [3]byte{'a', 'b', 'c'}, Level: 2}, &queryRaw{Code: [3]byte{'a', 'b', 'c'}, Level: 2}} {
+		values, err = EncodeQuery(params)
+		if err != nil {
+			t.Fatalf("EncodeQuery error %+v", err)
+		}
+		if encoded := values.Encode(); encoded != "code=abc&level=L2" {
+			t.Fatalf("EncodeQuery %s", encoded)
+		}
+	}
+	if _, err := EncodeQuery(map[int]string{1: "a"}); err == nil {
+		t.Fatalf("EncodeQuery should fail")
+	}
+	if _, err := EncodeQuery("a=b"); err == nil {
+		t.Fatalf("EncodeQuery should fail")
+	}
+
+	cases := []struct {
+		path   string
+		values url.Values
+		res    string
+	}{
+		{"/rpc", nil, "/rpc"},
+		{"/rpc", url.Values{"a": {"1"}}, "/rpc?a=1"},
+		{"/rpc?b=2&a=0", url.Values{"a": {"1"}}, "/rpc?a=0&a=1&b=2"},
+		{"/rpc?", url.Values{"a": {"1 2"}}, "/rpc?a=1+2"},
+	}
+	for _, c := range cases {
+		if res, err := appendQuery(c.path, c.values); err != nil || res != c.res {
+			t.Fatalf("appendQuery %s %+v = %s %+v", c.path, c.values, res, err)
+		}
+	}
+}
+
+func TestHttpQuery(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"errno":0,"errmsg":"` + r.URL.RawQuery + `"}`))
+	}))
+	defer server.Close()
+	client := newTestClient(t, server.URL)
+	defer client.(*httpClient).client.CloseIdleConnections()
+
+	var res testRes
+	if err := client.Get(context.TODO(), "/rpc?v=1", map[string]interface{}{"name": "a&b", "id": []int{1, 2}}, &res); err != nil {
+		t.Fatalf("client.Get error %+v", err)
+	} else if res.ErrMsg != "id=1&id=2&name=a%26b&v=1" {
+		t.Fatalf("client.Get query %s", res.ErrMsg)
+	}
+	if err := client.GetEx(context.TODO(), "/rpc", nil, &res, nil, WithQuery(queryPage{Page: 2, Size: 10}), WithQuery(url.Values{"page": {"3"}})); err != nil {
+		t.Fatalf("client.GetEx error %+v", err)
+	} else if res.ErrMsg != "page=2&page=3&size=10" {
+		t.Fatalf("client.GetEx query %s", res.ErrMsg)
+	}
+	if err := client.GetEx(context.TODO(), "/rpc", nil, &res, nil, WithQuery(1)); err == nil {
+		t.Fatalf("client.GetEx should fail")
+	}
+}
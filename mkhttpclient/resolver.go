@@ -0,0 +1,198 @@
+package mkhttpclient
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"net"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/zhongxuqi/mklibs/mklog"
+)
+
+// Resolver resolves a service into the hosts of its replicas, each a scheme and
+// host like http://10.0.0.1:8080
+type Resolver interface {
+	Resolve(ctx context.Context) ([]string, error)
+}
+
+// ResolverFunc ...
+type ResolverFunc func(ctx context.Context) ([]string, error)
+
+// Resolve ...
+func (f ResolverFunc) Resolve(ctx context.Context) ([]string, error) {
+	return f(ctx)
+}
+
+// NewStaticResolver returns a Resolver always resolving into hosts
+func NewStaticResolver(hosts ...string) Resolver {
+	return ResolverFunc(func(ctx context.Context) ([]string, error) {
+		return hosts, nil
+	})
+}
+
+// NewDNSResolver returns a Resolver looking up the A and AAAA records of name,
+// the hosts are made of scheme, the addresses and port
+func NewDNSResolver(scheme, name string, port int) Resolver {
+	return ResolverFunc(func(ctx context.Context) ([]string, error) {
+		addrs, err := net.DefaultResolver.LookupHost(ctx, name)
+		if err != nil {
+			return nil, err
+		}
+		hosts := make([]string, 0, len(addrs))
+		for _, addr := range addrs {
+			hosts = append(hosts, fmt.Sprintf("%s://%s", scheme, net.JoinHostPort(addr, strconv.Itoa(port))))
+		}
+		sort.Strings(hosts)
+		return hosts, nil
+	})
+}
+
+// NewSRVResolver returns a Resolver looking up the SRV records of
+// _service._proto.name, the hosts are made of scheme and the targets and ports
+// of the records
+func NewSRVResolver(scheme, service, proto, name string) Resolver {
+	return ResolverFunc(func(ctx context.Context) ([]string, error) {
+		_, records, err := net.DefaultResolver.LookupSRV(ctx, service, proto, name)
+		if err != nil {
+			return nil, err
+		}
+		hosts := make([]string, 0, len(records))
+		for _, record := range records {
+			target := strings.TrimSuffix(record.Target, ".")
+			hosts = append(hosts, fmt.Sprintf("%s://%s", scheme, net.JoinHostPort(target, strconv.Itoa(int(record.Port)))))
+		}
+		sort.Strings(hosts)
+		return hosts, nil
+	})
+}
+
+// NewFileResolver returns a Resolver reading the hosts from the file at path, one
+// per line, skipping empty lines and lines starting with #. The file is read
+// again at each resolution, so editing it updates the clients.
+func NewFileResolver(path string) Resolver {
+	return ResolverFunc(func(ctx context.Context) ([]string, error) {
+		content, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		hosts := make([]string, 0)
+		scanner := bufio.NewScanner(bytes.NewReader(content))
+		for scanner.Scan() {
+			line := strings.TrimSpace(scanner.Text())
+			if line == "" || strings.HasPrefix(line, "#") {
+				continue
+			}
+			hosts = append(hosts, line)
+		}
+		return hosts, scanner.Err()
+	})
+}
+
+// FakeResolver is a Resolver returning the hosts and error it is set with, for
+// tests
+type FakeResolver struct {
+	mutex sync.Mutex
+	hosts []string
+	err   error
+	calls int
+}
+
+// NewFakeResolver ...
+func NewFakeResolver(hosts ...string) *FakeResolver {
+	return &FakeResolver{hosts: hosts}
+}
+
+// Set sets the result of the next resolutions
+func (s *FakeResolver) Set(hosts []string, err error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.hosts = hosts
+	s.err = err
+}
+
+// Calls returns the number of resolutions
+func (s *FakeResolver) Calls() int {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.calls
+}
+
+// Resolve ...
+func (s *FakeResolver) Resolve(ctx context.Context) ([]string, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.calls++
+	return s.hosts, s.err
+}
+
+// NewResolverHTTPClient returns an HTTPClient balancing its requests over the
+// hosts of resolver, resolved again every interval until ctx is done. A failed
+// or empty resolution keeps the previous hosts.
+func NewResolverHTTPClient(ctx context.Context, resolver Resolver, interval time.Duration, options ...HTTPClientOption) (HTTPClient, error) {
+	if resolver == nil {
+		return nil, fmt.Errorf("nil Resolver")
+	}
+	if interval <= 0 {
+		return nil, fmt.Errorf("invalid resolve interval %s", interval)
+	}
+	hosts, err := resolver.Resolve(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if len(hosts) == 0 {
+		return nil, fmt.Errorf("no hosts")
+	}
+	client, err := newHTTPClient(newEndpointSet(hosts), options)
+	if err != nil {
+		return nil, err
+	}
+	go watchResolver(ctx, resolver, interval, client.endpoints)
+	return client, nil
+}
+
+// watchResolver feeds endpoints with the hosts of resolver every interval until
+// ctx is done
+func watchResolver(ctx context.Context, resolver Resolver, interval time.Duration, endpoints *endpointSet) {
+	ml := mklog.NewWithContext(ctx)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		hosts, err := resolver.Resolve(ctx)
+		if err != nil {
+			ml.Errorf("resolver.Resolve error %+v", err)
+			continue
+		}
+		if len(hosts) == 0 {
+			ml.Errorf("resolver.Resolve no hosts, keep %+v", endpoints.hosts())
+			continue
+		}
+		if previous := endpoints.hosts(); !equalHosts(previous, hosts) {
+			ml.Infof("resolved hosts %+v -> %+v", previous, hosts)
+			endpoints.update(hosts)
+		}
+	}
+}
+
+func equalHosts(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
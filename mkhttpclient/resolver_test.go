@@ -0,0 +1,100 @@
+package mkhttpclient
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestResolvers(t *testing.T) {
+	if hosts, err := NewStaticResolver("http://a", "http://b").Resolve(context.TODO()); err != nil || len(hosts) != 2 {
+		t.Fatalf("static resolver %+v %+v", hosts, err)
+	}
+
+	path := filepath.Join(t.TempDir(), "hosts")
+	if err := os.WriteFile(path, []byte("# replicas\nhttp://a:8080\n\n  http://b:8080  \n"), 0644); err != nil {
+		t.Fatalf("os.WriteFile error %+v", err)
+	}
+	resolver := NewFileResolver(path)
+	if hosts, err := resolver.Resolve(context.TODO()); err != nil || len(hosts) != 2 || hosts[1] != "http://b:8080" {
+		t.Fatalf("file resolver %+v %+v", hosts, err)
+	}
+	os.WriteFile(path, []byte("http://c:8080\n"), 0644)
+	if hosts, err := resolver.Resolve(context.TODO()); err != nil || len(hosts) != 1 || hosts[0] != "http://c:8080" {
+		t.Fatalf("file resolver %+v %+v", hosts, err)
+	}
+	if _, err := NewFileResolver(filepath.Join(t.TempDir(), "missing")).Resolve(context.TODO()); err == nil {
+		t.Fatalf("file resolver should fail")
+	}
+
+	hosts, err := NewDNSResolver("http", "localhost", 8080).Resolve(context.TODO())
+	if err != nil {
+		t.Skipf("dns lookup of localhost error %+v", err)
+	}
+	for _, host := range hosts {
+		if host != "http://127.0.0.1:8080" && host != "http://[::1]:8080" {
+			t.Fatalf("dns resolver %+v", hosts)
+		}
+	}
+}
+
+func TestHttpResolver(t *testing.T) {
+	checkGoroutines(t)
+	handler := func(name string) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte(`{"errno":0,"errmsg":"` + name + `"}`))
+		})
+	}
+	a := httptest.NewServer(handler("a"))
+	defer a.Close()
+	b := httptest.NewServer(handler("b"))
+	defer b.Close()
+
+	ctx, cancel := context.WithCancel(context.TODO())
+	defer cancel()
+	resolver := NewFakeResolver()
+	if _, err := NewResolverHTTPClient(ctx, resolver, time.Millisecond); err == nil {
+		t.Fatalf("NewResolverHTTPClient should fail without hosts")
+	}
+	resolver.Set([]string{a.URL}, nil)
+	client, err := NewResolverHTTPClient(ctx, resolver, 10*time.Millisecond)
+	if err != nil {
+		t.Fatalf("NewResolverHTTPClient error %+v", err)
+	}
+	defer client.(*httpClient).client.CloseIdleConnections()
+	var res testRes
+	if err := client.Get(context.TODO(), "/", nil, &res); err != nil || res.ErrMsg != "a" {
+		t.Fatalf("client.Get error %+v %+v", err, res)
+	}
+
+	// 解析失败时保留原有节点
+	resolver.Set(nil, errors.New("lookup failed"))
+	waitResolve(t, resolver)
+	if err := client.Get(context.TODO(), "/", nil, &res); err != nil || res.ErrMsg != "a" {
+		t.Fatalf("client.Get error %+v %+v", err, res)
+	}
+
+	resolver.Set([]string{b.URL}, nil)
+	waitResolve(t, resolver)
+	if err := client.Get(context.TODO(), "/", nil, &res); err != nil || res.ErrMsg != "b" {
+		t.Fatalf("client.Get error %+v %+v", err, res)
+	}
+	cancel()
+}
+
+// waitResolve waits for two more resolutions so the last result is applied
+func waitResolve(t *testing.T, resolver *FakeResolver) {
+	calls := resolver.Calls()
+	deadline := time.Now().Add(time.Second)
+	for resolver.Calls() < calls+2 {
+		if time.Now().After(deadline) {
+			t.Fatalf("resolver not called")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
@@ -0,0 +1,17 @@
+package mkhttpclient
+
+import (
+	"net/http"
+	"time"
+)
+
+// Response is the response of a call, filled by WithResponse whether the call
+// succeeds or fails. StatusCode is 0 when the last attempt received no response.
+type Response struct {
+	StatusCode int
+	Status     string
+	Header     http.Header
+	Body       []byte
+	Elapsed    time.Duration // from the start of the call to the end of the last attempt
+	Attempts   int
+}
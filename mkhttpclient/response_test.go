@@ -0,0 +1,57 @@
+package mkhttpclient
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestHttpResponse(t *testing.T) {
+	var count int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/flaky":
+			if atomic.AddInt32(&count, 1) == 1 {
+				w.WriteHeader(http.StatusServiceUnavailable)
+				return
+			}
+		case "/missing":
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(`{"errno":404}`))
+			return
+		}
+		w.Header().Set("ETag", `"v1"`)
+		w.Write([]byte(`{"errno":0,"errmsg":"success"}`))
+	}))
+	defer server.Close()
+	client := newTestClient(t, server.URL)
+	defer client.(*httpClient).client.CloseIdleConnections()
+
+	// WithResponse只能用于单次调用
+	if _, err := NewHTTPClient(server.URL, WithResponse(&Response{})); err == nil {
+		t.Fatalf("NewHTTPClient should fail")
+	}
+
+	var res testRes
+	var response Response
+	if err := client.GetEx(context.TODO(), "/flaky", nil, &res, nil, WithRetryTimes(1), WithRetryHttpError(true), WithResponse(&response)); err != nil {
+		t.Fatalf("client.GetEx error %+v", err)
+	}
+	if response.StatusCode != http.StatusOK || response.Header.Get("ETag") != `"v1"` || string(response.Body) != `{"errno":0,"errmsg":"success"}` ||
+		response.Attempts != 2 || response.Elapsed <= 0 || response.Elapsed > time.Second {
+		t.Fatalf("response error %+v", response)
+	}
+
+	if _, err := GetJSON[testRes](context.TODO(), client, "/missing", nil, WithResponse(&response)); StatusCode(err) != http.StatusNotFound {
+		t.Fatalf("GetJSON error %+v", err)
+	}
+	if response.StatusCode != http.StatusNotFound || string(response.Body) != `{"errno":404}` || response.Attempts != 1 || response.Header.Get("ETag") != "" {
+		t.Fatalf("response error %+v", response)
+	}
+	if err := client.GetEx(context.TODO(), "/", nil, &res, nil, WithResponse(nil)); err == nil {
+		t.Fatalf("client.GetEx should fail")
+	}
+}
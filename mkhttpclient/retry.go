@@ -0,0 +1,197 @@
+package mkhttpclient
+
+import (
+	"math/rand"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/zhongxuqi/mklibs/mkerr"
+)
+
+// RetryAttempt is the outcome of a failed attempt a RetryPolicy decides on
+type RetryAttempt struct {
+	Method     string
+	URL        string
+	Attempt    int         // 1 for the first request
+	StatusCode int         // 0 when no response was received
+	Header     http.Header // response header, nil when no response was received
+	Err        error
+}
+
+// RetryPolicy decides whether a failed attempt is retried and how long to wait
+// before the next one. The number of attempts is still bounded by RetryTimes.
+type RetryPolicy interface {
+	Retry(attempt RetryAttempt) (bool, time.Duration)
+}
+
+// RetryPolicyFunc ...
+type RetryPolicyFunc func(attempt RetryAttempt) (bool, time.Duration)
+
+// Retry ...
+func (f RetryPolicyFunc) Retry(attempt RetryAttempt) (bool, time.Duration) {
+	return f(attempt)
+}
+
+// defaultRetryPolicy retries at once on transport errors and timeouts, and on
+// http error statuses when retryHttpError is set
+type defaultRetryPolicy struct {
+	retryHttpError bool
+}
+
+// Retry ...
+func (s defaultRetryPolicy) Retry(attempt RetryAttempt) (bool, time.Duration) {
+	if attempt.StatusCode == 0 {
+		return mkerr.IsRetryable(attempt.Err), 0
+	}
+	return attempt.StatusCode/100 != 2 && s.retryHttpError, 0
+}
+
+// BackoffRetryPolicy retries idempotent requests failing with a retryable error
+// or status, waiting a random delay between 0 and BaseDelay*2^(attempt-1), at
+// most MaxDelay (exponential backoff with full jitter). The Retry-After header of
+// 429 and 503 responses is honored instead, giving up when it exceeds MaxDelay.
+type BackoffRetryPolicy struct {
+	BaseDelay          time.Duration
+	MaxDelay           time.Duration
+	RetryStatusCodes   []int
+	RetryNonIdempotent bool // retry POST and PATCH requests too
+}
+
+// NewBackoffRetryPolicy returns a BackoffRetryPolicy retrying 429, 502, 503 and
+// 504 statuses
+func NewBackoffRetryPolicy(baseDelay, maxDelay time.Duration) *BackoffRetryPolicy {
+	return &BackoffRetryPolicy{
+		BaseDelay: baseDelay,
+		MaxDelay:  maxDelay,
+		RetryStatusCodes: []int{
+			http.StatusTooManyRequests,
+			http.StatusBadGateway,
+			http.StatusServiceUnavailable,
+			http.StatusGatewayTimeout,
+		},
+	}
+}
+
+// Retry ...
+func (s *BackoffRetryPolicy) Retry(attempt RetryAttempt) (bool, time.Duration) {
+	if !s.RetryNonIdempotent && !isIdempotent(attempt.Method) {
+		return false, 0
+	}
+	if !s.retryable(attempt) {
+		return false, 0
+	}
+	if attempt.StatusCode == http.StatusTooManyRequests || attempt.StatusCode == http.StatusServiceUnavailable {
+		if delay, ok := parseRetryAfter(attempt.Header, time.Now()); ok {
+			if s.MaxDelay > 0 && delay > s.MaxDelay {
+				return false, 0
+			}
+			return true, delay
+		}
+	}
+	return true, s.backoff(attempt.Attempt)
+}
+
+func (s *BackoffRetryPolicy) retryable(attempt RetryAttempt) bool {
+	if attempt.StatusCode == 0 {
+		return mkerr.IsRetryable(attempt.Err)
+	}
+	for _, statusCode := range s.RetryStatusCodes {
+		if attempt.StatusCode == statusCode {
+			return true
+		}
+	}
+	return false
+}
+
+// backoff returns a random delay up to the exponential backoff of attempt
+func (s *BackoffRetryPolicy) backoff(attempt int) time.Duration {
+	if s.BaseDelay <= 0 {
+		return 0
+	}
+	delay := s.BaseDelay
+	for i := 1; i < attempt; i++ {
+		if s.MaxDelay > 0 && delay >= s.MaxDelay {
+			break
+		}
+		delay *= 2
+	}
+	if s.MaxDelay > 0 && delay > s.MaxDelay {
+		delay = s.MaxDelay
+	}
+	return time.Duration(rand.Int63n(int64(delay) + 1))
+}
+
+// isIdempotent reports whether requests of method may be sent again safely
+func isIdempotent(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodTrace, http.MethodPut, http.MethodDelete:
+		return true
+	}
+	return false
+}
+
+// parseRetryAfter parses the Retry-After header, given in seconds or as an http
+// date
+func parseRetryAfter(header http.Header, now time.Time) (time.Duration, bool) {
+	value := header.Get("Retry-After")
+	if value == "" {
+		return 0, false
+	}
+	if seconds, err := strconv.Atoi(value); err == nil {
+		if seconds < 0 {
+			return 0, false
+		}
+		return time.Duration(seconds) * time.Second, true
+	}
+	date, err := http.ParseTime(value)
+	if err != nil {
+		return 0, false
+	}
+	if delay := date.Sub(now); delay > 0 {
+		return delay, true
+	}
+	return 0, true
+}
+
+// RetryBudget limits retries when most requests fail, so a dead downstream is
+// not flooded with retries. Each retry takes a token and each success gives back
+// tokenRatio tokens, retries are refused while no more than half of maxTokens
+// are left. Share one budget between the calls to a downstream.
+type RetryBudget struct {
+	mutex      sync.Mutex
+	maxTokens  float64
+	tokenRatio float64
+	tokens     float64
+}
+
+// NewRetryBudget ...
+func NewRetryBudget(maxTokens, tokenRatio float64) *RetryBudget {
+	return &RetryBudget{
+		maxTokens:  maxTokens,
+		tokenRatio: tokenRatio,
+		tokens:     maxTokens,
+	}
+}
+
+// withdraw takes a token for a retry, it reports whether the retry is allowed
+func (s *RetryBudget) withdraw() bool {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.tokens--
+	if s.tokens < 0 {
+		s.tokens = 0
+	}
+	return s.tokens > s.maxTokens/2
+}
+
+// deposit gives back tokens for a successful request
+func (s *RetryBudget) deposit() {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.tokens += s.tokenRatio
+	if s.tokens > s.maxTokens {
+		s.tokens = s.maxTokens
+	}
+}
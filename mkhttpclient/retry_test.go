@@ -0,0 +1,134 @@
+package mkhttpclient
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/zhongxuqi/mklibs/mkerr"
+)
+
+func TestBackoffRetryPolicy(t *testing.T) {
+	policy := NewBackoffRetryPolicy(10*time.Millisecond, 50*time.Millisecond)
+	for attempt, maxDelay := range []time.Duration{0, 10 * time.Millisecond, 20 * time.Millisecond, 40 * time.Millisecond, 50 * time.Millisecond, 50 * time.Millisecond} {
+		if attempt == 0 {
+			continue
+		}
+		for i := 0; i < 100; i++ {
+			retry, delay := policy.Retry(RetryAttempt{Method: http.MethodGet, Attempt: attempt, StatusCode: http.StatusBadGateway})
+			if !retry || delay < 0 || delay > maxDelay {
+				t.Fatalf("attempt %d retry %v delay %s", attempt, retry, delay)
+			}
+		}
+	}
+
+	timeoutErr := mkerr.Timeout(ErrNoTimeout, "http request timeout", "")
+	cases := []struct {
+		attempt RetryAttempt
+		retry   bool
+	}{
+		{RetryAttempt{Method: http.MethodGet, Attempt: 1, Err: timeoutErr}, true},
+		{RetryAttempt{Method: http.MethodGet, Attempt: 1, Err: mkerr.Canceled(ErrNoCanceled, "http request canceled", "")}, false},
+		{RetryAttempt{Method: http.MethodGet, Attempt: 1, StatusCode: http.StatusNotFound}, false},
+		{RetryAttempt{Method: http.MethodPost, Attempt: 1, Err: timeoutErr}, false},
+		{RetryAttempt{Method: http.MethodDelete, Attempt: 1, StatusCode: http.StatusServiceUnavailable}, true},
+	}
+	for _, c := range cases {
+		if retry, _ := policy.Retry(c.attempt); retry != c.retry {
+			t.Fatalf("policy.Retry %+v = %v", c.attempt, retry)
+		}
+	}
+	policy.RetryNonIdempotent = true
+	if retry, _ := policy.Retry(RetryAttempt{Method: http.MethodPost, Attempt: 1, Err: timeoutErr}); !retry {
+		t.Fatalf("policy.Retry should retry POST")
+	}
+
+	// Retry-After
+	header := http.Header{}
+	header.Set("Retry-After", "0")
+	if retry, delay := policy.Retry(RetryAttempt{Method: http.MethodGet, Attempt: 3, StatusCode: http.StatusTooManyRequests, Header: header}); !retry || delay != 0 {
+		t.Fatalf("policy.Retry Retry-After %v %s", retry, delay)
+	}
+	header.Set("Retry-After", "120")
+	if retry, _ := policy.Retry(RetryAttempt{Method: http.MethodGet, Attempt: 1, StatusCode: http.StatusServiceUnavailable, Header: header}); retry {
+		t.Fatalf("policy.Retry should give up on long Retry-After")
+	}
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	now := time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC)
+	cases := []struct {
+		value string
+		delay time.Duration
+		ok    bool
+	}{
+		{"", 0, false},
+		{"3", 3 * time.Second, true},
+		{"-1", 0, false},
+		{"Sat, 01 Jan 2022 00:00:05 GMT", 5 * time.Second, true},
+		{"Fri, 31 Dec 2021 23:59:00 GMT", 0, true},
+		{"soon", 0, false},
+	}
+	for _, c := range cases {
+		header := http.Header{}
+		if c.value != "" {
+			header.Set("Retry-After", c.value)
+		}
+		if delay, ok := parseRetryAfter(header, now); delay != c.delay || ok != c.ok {
+			t.Fatalf("parseRetryAfter %q = %s %v", c.value, delay, ok)
+		}
+	}
+}
+
+func TestRetryBudget(t *testing.T) {
+	budget := NewRetryBudget(4, 0.5)
+	if !budget.withdraw() {
+		t.Fatalf("budget.withdraw should allow")
+	}
+	if budget.withdraw() {
+		t.Fatalf("budget.withdraw should refuse")
+	}
+	for i := 0; i < 4; i++ {
+		budget.deposit()
+	}
+	if !budget.withdraw() {
+		t.Fatalf("budget.withdraw should allow after deposits")
+	}
+}
+
+func TestHttpRetryPolicy(t *testing.T) {
+	var count int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&count, 1)%3 != 0 {
+			w.Header().Set("Retry-After", "0")
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.Write([]byte(`{"errno":0,"errmsg":"success"}`))
+	}))
+	defer server.Close()
+	client := newTestClient(t, server.URL, WithRetryTimes(3), WithRetryPolicy(NewBackoffRetryPolicy(time.Millisecond, 10*time.Millisecond)))
+	defer client.(*httpClient).client.CloseIdleConnections()
+
+	var res testRes
+	if err := client.Get(context.TODO(), "/", nil, &res); err != nil {
+		t.Fatalf("client.Get error %+v", err)
+	} else if res.ErrMsg != "success" || atomic.LoadInt32(&count) != 3 {
+		t.Fatalf("client.Get data error %+v %d", res, count)
+	}
+
+	// POST 不重试
+	atomic.StoreInt32(&count, 0)
+	if err := client.PostJSON(context.TODO(), "/", nil, &res); StatusCode(err) != http.StatusServiceUnavailable || atomic.LoadInt32(&count) != 1 {
+		t.Fatalf("client.PostJSON error %+v %d", err, count)
+	}
+
+	// 重试预算耗尽
+	atomic.StoreInt32(&count, 0)
+	if err := client.GetEx(context.TODO(), "/", nil, &res, nil, WithRetryBudget(NewRetryBudget(2, 0.1))); StatusCode(err) != http.StatusServiceUnavailable || atomic.LoadInt32(&count) != 1 {
+		t.Fatalf("client.GetEx error %+v %d", err, count)
+	}
+}
@@ -0,0 +1,204 @@
+package mkhttpclient
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+
+	"github.com/zhongxuqi/mklibs/mklog"
+)
+
+// errBodyNotReplayable is returned when a request body read by a previous
+// attempt is needed again
+var errBodyNotReplayable = errors.New("request body can not be replayed")
+
+// requestBody opens the body of each attempt of a request
+type requestBody struct {
+	get     func() (io.ReadCloser, error)
+	length  int64 // -1 when unknown
+	oneShot bool  // the body can only be sent once, the request is not retried
+}
+
+// bytesBody returns the body of b, nil for an empty body
+func bytesBody(b []byte) *requestBody {
+	if len(b) == 0 {
+		return nil
+	}
+	return &requestBody{
+		get: func() (io.ReadCloser, error) {
+			return io.NopCloser(bytes.NewReader(b)), nil
+		},
+		length: int64(len(b)),
+	}
+}
+
+// readerBody returns the body read from r. Bodies implementing io.Seeker, like
+// files, are replayed by seeking back to their current offset, other readers
+// are sent once.
+func readerBody(r io.Reader) *requestBody {
+	if r == nil {
+		return nil
+	}
+	if buf, ok := r.(*bytes.Buffer); ok {
+		return bytesBody(buf.Bytes())
+	}
+	if seeker, ok := r.(io.Seeker); ok {
+		if offset, err := seeker.Seek(0, io.SeekCurrent); err == nil {
+			length := int64(-1)
+			if end, err := seeker.Seek(0, io.SeekEnd); err == nil {
+				length = end - offset
+			}
+			guard := &bodyGuard{}
+			return &requestBody{
+				get: func() (io.ReadCloser, error) {
+					guard.wait()
+					if _, err := seeker.Seek(offset, io.SeekStart); err != nil {
+						return nil, err
+					}
+					return guard.track(r), nil
+				},
+				length: length,
+			}
+		}
+	}
+	used := false
+	return &requestBody{
+		get: func() (io.ReadCloser, error) {
+			if used {
+				return nil, errBodyNotReplayable
+			}
+			used = true
+			return io.NopCloser(r), nil
+		},
+		length:  -1,
+		oneShot: true,
+	}
+}
+
+// bodyGuard keeps an attempt from replaying a body still read by the previous
+// attempt: the transport may go on sending a body after the response arrives,
+// until it closes the body
+type bodyGuard struct {
+	done chan struct{}
+}
+
+// wait blocks until the body of the previous attempt is released
+func (s *bodyGuard) wait() {
+	if s.done != nil {
+		<-s.done
+	}
+}
+
+// track returns the body of an attempt reading r, released when closed
+func (s *bodyGuard) track(r io.Reader) io.ReadCloser {
+	body := &trackedBody{Reader: r, done: make(chan struct{})}
+	s.done = body.done
+	return body
+}
+
+// trackedBody is a request body closing done when closed by the transport
+type trackedBody struct {
+	io.Reader
+	once sync.Once
+	done chan struct{}
+}
+
+// Close ...
+func (s *trackedBody) Close() error {
+	s.once.Do(func() {
+		close(s.done)
+	})
+	return nil
+}
+
+// SendEx sends a request whose body is read from body, without buffering it.
+// See readerBody for the bodies replayed on retries.
+func (s *httpClient) SendEx(ctx context.Context, method, path string, body io.Reader, res interface{}, header map[string]string, options ...HTTPClientOption) error {
+	ml := mklog.NewWithContext(ctx)
+	ml.Infof("url [%s]%s%s header %+v", method, s.host(), path, header)
+	return s.do(ctx, method, path, readerBody(body), res, header, options...)
+}
+
+// Stream sends a request whose body is read from body and returns the body of
+// its 2xx response unread, the caller must close it. Reading the body is bounded
+// by the timeout of its attempt, TotalTimeout when it is the last one, 5s by
+// default, and by CallTimeout and ctx: raise them for large downloads.
+func (s *httpClient) Stream(ctx context.Context, method, path string, body io.Reader, header map[string]string, options ...HTTPClientOption) (io.ReadCloser, error) {
+	ml := mklog.NewWithContext(ctx)
+	ml.Infof("url [%s]%s%s header %+v", method, s.host(), path, header)
+	var res io.ReadCloser
+	streamOption := func(config *Config) error {
+		config.stream = &res
+		return nil
+	}
+	if err := s.do(ctx, method, path, readerBody(body), nil, header, append(options[:len(options):len(options)], streamOption)...); err != nil {
+		return nil, err
+	}
+	if res == nil {
+		return io.NopCloser(bytes.NewReader(nil)), nil
+	}
+	return res, nil
+}
+
+// streamBody is a response body returned by Stream, canceling the contexts of
+// its attempt and call when closed
+type streamBody struct {
+	io.ReadCloser
+	cancels []context.CancelFunc
+}
+
+// Close ...
+func (s *streamBody) Close() error {
+	err := s.ReadCloser.Close()
+	for _, cancel := range s.cancels {
+		cancel()
+	}
+	return err
+}
+
+// prefixWriter keeps the first limit bytes written to it
+type prefixWriter struct {
+	limit int
+	buf   []byte
+	n     int64
+}
+
+// Write ...
+func (s *prefixWriter) Write(p []byte) (int, error) {
+	s.n += int64(len(p))
+	if rest := s.limit - len(s.buf); rest > 0 {
+		if len(p) < rest {
+			rest = len(p)
+		}
+		s.buf = append(s.buf, p[:rest]...)
+	}
+	return len(p), nil
+}
+
+// logBody returns the first limit bytes of a body of size n for logging
+func logBody(prefix []byte, n int64, limit int) string {
+	if int64(len(prefix)) > int64(limit) {
+		prefix = prefix[:limit]
+	}
+	if int64(len(prefix)) < n {
+		return fmt.Sprintf("%s...(%d bytes)", prefix, n)
+	}
+	return string(prefix)
+}
+
+// copyResponse streams the body of httpRes to w
+func copyResponse(ctx context.Context, httpRes *http.Response, w io.Writer, currConfig Config) (int, error) {
+	ml := mklog.NewWithContext(ctx)
+	prefix := &prefixWriter{limit: currConfig.LogBodyLimit}
+	if _, err := io.Copy(io.MultiWriter(w, prefix), httpRes.Body); err != nil {
+		ml.Errorf("io.Copy error %+v", err)
+		// 已写入部分数据, 不能重试
+		return httpRes.StatusCode, transportError(err)
+	}
+	ml.Infof("response body: %s", logBody(prefix.buf, prefix.n, currConfig.LogBodyLimit))
+	return httpRes.StatusCode, nil
+}
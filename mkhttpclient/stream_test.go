@@ -0,0 +1,170 @@
+package mkhttpclient
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestHttpStreamRequest(t *testing.T) {
+	var count int32
+	bodies := make(chan string, 10)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		bodies <- string(body)
+		if atomic.AddInt32(&count, 1) == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.Write([]byte(`{"errno":0,"errmsg":"` + strconv.FormatInt(r.ContentLength, 10) + `"}`))
+	}))
+	defer server.Close()
+	client := newTestClient(t, server.URL, WithRetryTimes(1), WithRetryHttpError(true))
+	defer client.(*httpClient).client.CloseIdleConnections()
+
+	// 可重放的body
+	var res testRes
+	if err := client.SendEx(context.TODO(), http.MethodPut, "/", strings.NewReader("payload"), &res, nil); err != nil {
+		t.Fatalf("client.SendEx error %+v", err)
+	}
+	if first, second := <-bodies, <-bodies; first != "payload" || second != "payload" || res.ErrMsg != "7" {
+		t.Fatalf("bodies %q %q res %+v", first, second, res)
+	}
+
+	path := filepath.Join(t.TempDir(), "upload")
+	os.WriteFile(path, []byte("file content"), 0644)
+	file, err := os.Open(path)
+	if err != nil {
+		t.Fatalf("os.Open error %+v", err)
+	}
+	defer file.Close()
+	atomic.StoreInt32(&count, 0)
+	if err := client.SendEx(context.TODO(), http.MethodPut, "/", file, &res, nil); err != nil {
+		t.Fatalf("client.SendEx error %+v", err)
+	}
+	if first, second := <-bodies, <-bodies; first != "file content" || second != "file content" || res.ErrMsg != "12" {
+		t.Fatalf("bodies %q %q res %+v", first, second, res)
+	}
+
+	// 不可重放的body只发送一次
+	atomic.StoreInt32(&count, 0)
+	if err := client.SendEx(context.TODO(), http.MethodPut, "/", io.MultiReader(strings.NewReader("once")), &res, nil); StatusCode(err) != http.StatusServiceUnavailable {
+		t.Fatalf("client.SendEx error %+v", err)
+	}
+	if body := <-bodies; body != "once" || atomic.LoadInt32(&count) != 1 {
+		t.Fatalf("body %q count %d", body, count)
+	}
+}
+
+// respondUnavailableEarly answers 503 before reading the body of r and then
+// reads it slowly, so the client retries while still sending the body
+func respondUnavailableEarly(w http.ResponseWriter, r *http.Request) {
+	conn, buf, err := w.(http.Hijacker).Hijack()
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+	buf.WriteString("HTTP/1.1 503 Service Unavailable\r\nContent-Length: 2\r\n\r\n{}")
+	buf.Flush()
+	for n := int64(0); n < r.ContentLength; n += 64 * 1024 {
+		if _, err := io.CopyN(io.Discard, buf, 64*1024); err != nil {
+			return
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestHttpStreamRequestRetry(t *testing.T) {
+	var count int32
+	payload := bytes.Repeat([]byte("0123456789abcdef"), 512*1024)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&count, 1) < 4 {
+			respondUnavailableEarly(w, r)
+			return
+		}
+		body, _ := ioutil.ReadAll(r.Body)
+		if !bytes.Equal(body, payload) {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		w.Write([]byte(`{"errno":0,"errmsg":"success"}`))
+	}))
+	defer server.Close()
+	client := newTestClient(t, server.URL, WithRetryTimes(3), WithRetryHttpError(true))
+	defer client.(*httpClient).client.CloseIdleConnections()
+
+	var res testRes
+	if err := client.SendEx(context.TODO(), http.MethodPut, "/", bytes.NewReader(payload), &res, nil); err != nil {
+		t.Fatalf("client.SendEx error %+v", err)
+	}
+	if res.ErrMsg != "success" || atomic.LoadInt32(&count) != 4 {
+		t.Fatalf("client.SendEx data error %+v %d", res, count)
+	}
+}
+
+func TestHttpStreamResponse(t *testing.T) {
+	checkGoroutines(t)
+	payload := strings.Repeat("0123456789", 1000)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/missing" {
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(`{"errno":404,"errmsg":"not found"}`))
+			return
+		}
+		w.Write([]byte(payload))
+	}))
+	defer server.Close()
+	client := newTestClient(t, server.URL)
+	defer client.(*httpClient).client.CloseIdleConnections()
+
+	var buf bytes.Buffer
+	var response Response
+	if err := client.GetEx(context.TODO(), "/", nil, nil, nil, WithResponseWriter(&buf), WithResponse(&response), WithLogBodyLimit(16)); err != nil {
+		t.Fatalf("client.GetEx error %+v", err)
+	}
+	if buf.String() != payload || response.StatusCode != http.StatusOK || response.Body != nil {
+		t.Fatalf("client.GetEx streamed %d bytes response %+v", buf.Len(), response)
+	}
+	buf.Reset()
+	if err := client.GetEx(context.TODO(), "/missing", nil, nil, nil, WithResponseWriter(&buf)); StatusCode(err) != http.StatusNotFound || buf.Len() != 0 {
+		t.Fatalf("client.GetEx error %+v", err)
+	}
+
+	body, err := client.Stream(context.TODO(), http.MethodGet, "/", nil, nil)
+	if err != nil {
+		t.Fatalf("client.Stream error %+v", err)
+	}
+	content, err := ioutil.ReadAll(body)
+	body.Close()
+	if err != nil || string(content) != payload {
+		t.Fatalf("client.Stream read %d bytes error %+v", len(content), err)
+	}
+	if _, err := client.Stream(context.TODO(), http.MethodGet, "/missing", nil, nil); StatusCode(err) != http.StatusNotFound {
+		t.Fatalf("client.Stream error %+v", err)
+	}
+}
+
+func TestLogBody(t *testing.T) {
+	prefix := &prefixWriter{limit: 4}
+	prefix.Write([]byte("ab"))
+	prefix.Write([]byte("cdef"))
+	if res := logBody(prefix.buf, prefix.n, 4); res != "abcd...(6 bytes)" {
+		t.Fatalf("logBody %s", res)
+	}
+	if res := logBody([]byte("abc"), 3, 4); res != "abc" {
+		t.Fatalf("logBody %s", res)
+	}
+	if res := logBody([]byte("abc"), 3, 0); res != "...(3 bytes)" {
+		t.Fatalf("logBody %s", res)
+	}
+}
@@ -0,0 +1,63 @@
+package mkhttpclient
+
+import (
+	"context"
+)
+
+// GetJSON sends a GET request with the query params and decodes the JSON
+// response into a T. Empty bodies and 204 responses give the zero T, decode
+// errors are returned.
+func GetJSON[T any](ctx context.Context, client HTTPClient, path string, params map[string]interface{}, options ...HTTPClientOption) (T, error) {
+	var res T
+	if err := client.GetEx(ctx, path, params, &res, nil, typedOptions(options)...); err != nil {
+		var zero T
+		return zero, err
+	}
+	return res, nil
+}
+
+// DeleteJSON sends a DELETE request and decodes the JSON response into a T
+func DeleteJSON[T any](ctx context.Context, client HTTPClient, path string, options ...HTTPClientOption) (T, error) {
+	var res T
+	if err := client.DeleteEx(ctx, path, &res, nil, typedOptions(options)...); err != nil {
+		var zero T
+		return zero, err
+	}
+	return res, nil
+}
+
+// PostJSON sends req as a JSON POST request and decodes the JSON response into
+// a Resp
+func PostJSON[Req, Resp any](ctx context.Context, client HTTPClient, path string, req Req, options ...HTTPClientOption) (Resp, error) {
+	var res Resp
+	if err := client.PostJSONEx(ctx, path, req, &res, nil, typedOptions(options)...); err != nil {
+		var zero Resp
+		return zero, err
+	}
+	return res, nil
+}
+
+// PutJSON sends req as a JSON PUT request and decodes the JSON response into a
+// Resp
+func PutJSON[Req, Resp any](ctx context.Context, client HTTPClient, path string, req Req, options ...HTTPClientOption) (Resp, error) {
+	var res Resp
+	if err := client.PutJSONEx(ctx, path, req, &res, nil, typedOptions(options)...); err != nil {
+		var zero Resp
+		return zero, err
+	}
+	return res, nil
+}
+
+// typedOptions appends to a copy of options an option enabling FailOnDecodeError
+// unless the client or the call set it, so they may still disable it
+func typedOptions(options []HTTPClientOption) []HTTPClientOption {
+	res := make([]HTTPClientOption, 0, len(options)+1)
+	res = append(res, options...)
+	return append(res, func(config *Config) error {
+		if config.FailOnDecodeError == nil {
+			enable := true
+			config.FailOnDecodeError = &enable
+		}
+		return nil
+	})
+}
@@ -0,0 +1,87 @@
+package mkhttpclient
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/zhongxuqi/mklibs/mkerr"
+)
+
+func TestTypedHelpers(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/user":
+			if r.Method == http.MethodGet {
+				w.Write([]byte(`{"errno":0,"errmsg":"` + r.URL.Query().Get("name") + `"}`))
+				return
+			}
+			body, _ := ioutil.ReadAll(r.Body)
+			w.Write(body)
+		case "/empty":
+			w.WriteHeader(http.StatusOK)
+		case "/nocontent":
+			w.WriteHeader(http.StatusNoContent)
+		case "/invalid":
+			w.Write([]byte(`{"errno":"zero"`))
+		case "/extra":
+			w.Write([]byte(`{"errno":0,"errmsg":"ok","extra":1}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(`{"errno":404,"errmsg":"not found"}`))
+		}
+	}))
+	defer server.Close()
+	client := newTestClient(t, server.URL)
+	defer client.(*httpClient).client.CloseIdleConnections()
+	ctx := context.TODO()
+
+	if res, err := GetJSON[testRes](ctx, client, "/user", map[string]interface{}{"name": "mk"}); err != nil || res.ErrMsg != "mk" {
+		t.Fatalf("GetJSON error %+v %+v", err, res)
+	}
+	if res, err := PostJSON[testRes, *testRes](ctx, client, "/user", testRes{ErrNo: 1, ErrMsg: "post"}); err != nil || res == nil || res.ErrNo != 1 || res.ErrMsg != "post" {
+		t.Fatalf("PostJSON error %+v %+v", err, res)
+	}
+	if res, err := PutJSON[map[string]interface{}, map[string]interface{}](ctx, client, "/user", map[string]interface{}{"key": "put"}); err != nil || res["key"] != "put" {
+		t.Fatalf("PutJSON error %+v %+v", err, res)
+	}
+	for _, path := range []string{"/empty", "/nocontent"} {
+		if res, err := DeleteJSON[*testRes](ctx, client, path); err != nil || res != nil {
+			t.Fatalf("DeleteJSON %s error %+v %+v", path, err, res)
+		}
+	}
+	if res, err := GetJSON[testRes](ctx, client, "/invalid", nil); err == nil || mkerr.CategoryOf(err) != mkerr.CategoryInternal || res.ErrNo != 0 {
+		t.Fatalf("GetJSON error %+v %+v", err, res)
+	} else if syntaxErr := (*json.SyntaxError)(nil); !errors.As(err, &syntaxErr) {
+		t.Fatalf("GetJSON error %+v does not wrap the json error", err)
+	}
+	if _, err := GetJSON[testRes](ctx, client, "/invalid", nil, WithFailOnDecodeError(false)); err != nil {
+		t.Fatalf("GetJSON error %+v", err)
+	}
+	// 客户端关闭FailOnDecodeError时不被覆盖, 调用时仍可开启
+	lenient := newTestClient(t, server.URL, WithFailOnDecodeError(false))
+	defer lenient.(*httpClient).client.CloseIdleConnections()
+	if _, err := GetJSON[testRes](ctx, lenient, "/invalid", nil); err != nil {
+		t.Fatalf("GetJSON error %+v", err)
+	}
+	if _, err := GetJSON[testRes](ctx, lenient, "/invalid", nil, WithFailOnDecodeError(true)); mkerr.CategoryOf(err) != mkerr.CategoryInternal {
+		t.Fatalf("GetJSON error %+v", err)
+	}
+	var res testRes
+	if err := client.GetEx(ctx, "/invalid", nil, &res, nil); err != nil {
+		t.Fatalf("client.GetEx error %+v", err)
+	}
+	if res, err := GetJSON[testRes](ctx, client, "/extra", nil); err != nil || res.ErrMsg != "ok" {
+		t.Fatalf("GetJSON error %+v %+v", err, res)
+	}
+	if _, err := GetJSON[testRes](ctx, client, "/extra", nil, WithStrictDecode(true)); mkerr.CategoryOf(err) != mkerr.CategoryInternal {
+		t.Fatalf("GetJSON error %+v", err)
+	}
+	if res, err := GetJSON[testRes](ctx, client, "/missing", nil); StatusCode(err) != http.StatusNotFound || res.ErrNo != 0 {
+		t.Fatalf("GetJSON error %+v %+v", err, res)
+	}
+}
@@ -0,0 +1,155 @@
+package mklog
+
+import (
+	"sync"
+)
+
+// Hook receives every record at or above Level() before it is written. Fire may
+// enrich the record, e.g. add fields, and returns false to drop it.
+type Hook interface {
+	Level() Level
+	Fire(record *Record) bool
+}
+
+// HookFunc adapts a function to a Hook.
+type HookFunc struct {
+	MinLevel Level
+	Func     func(record *Record) bool
+}
+
+func (s HookFunc) Level() Level {
+	return s.MinLevel
+}
+
+func (s HookFunc) Fire(record *Record) bool {
+	return s.Func(record)
+}
+
+type asyncHook struct {
+	hook    Hook
+	records chan *Record
+
+	mutex   sync.Mutex
+	done    *sync.Cond // signaled when pending drops to zero
+	pending int
+	closed  bool
+}
+
+// enqueue queues a copy of record, or discards it when the queue is full or the
+// hook was reset.
+func (s *asyncHook) enqueue(record *Record) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if s.closed {
+		return
+	}
+	select {
+	case s.records <- record.clone():
+		s.pending++
+	default:
+	}
+}
+
+func (s *asyncHook) run() {
+	for record := range s.records {
+		s.hook.Fire(record)
+		s.mutex.Lock()
+		s.pending--
+		if s.pending == 0 {
+			s.done.Broadcast()
+		}
+		s.mutex.Unlock()
+	}
+}
+
+func (s *asyncHook) flush() {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	for s.pending > 0 {
+		s.done.Wait()
+	}
+}
+
+func (s *asyncHook) close() {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if !s.closed {
+		s.closed = true
+		close(s.records)
+	}
+}
+
+var (
+	hookMutex  sync.RWMutex
+	syncHooks  []Hook
+	asyncHooks []*asyncHook
+)
+
+// AddHook registers a hook that runs synchronously in the logging goroutine, in
+// registration order, before the record is written.
+func AddHook(hook Hook) {
+	hookMutex.Lock()
+	defer hookMutex.Unlock()
+	syncHooks = append(syncHooks, hook)
+}
+
+// AddAsyncHook registers a hook that runs in its own goroutine after the
+// synchronous hooks. It receives a copy of the record, so it can neither enrich
+// nor drop it. Records are discarded when more than queueSize are waiting.
+func AddAsyncHook(hook Hook, queueSize int) {
+	h := &asyncHook{
+		hook:    hook,
+		records: make(chan *Record, queueSize),
+	}
+	h.done = sync.NewCond(&h.mutex)
+	go h.run()
+	hookMutex.Lock()
+	defer hookMutex.Unlock()
+	asyncHooks = append(asyncHooks, h)
+}
+
+// FlushHooks blocks until the async hooks have handled every queued record.
+func FlushHooks() {
+	hookMutex.RLock()
+	hooks := append([]*asyncHook(nil), asyncHooks...)
+	hookMutex.RUnlock()
+	for _, h := range hooks {
+		h.flush()
+	}
+}
+
+// ResetHooks removes all registered hooks, after flushing the async ones.
+func ResetHooks() {
+	FlushHooks()
+	hookMutex.Lock()
+	defer hookMutex.Unlock()
+	for _, h := range asyncHooks {
+		h.close()
+	}
+	syncHooks = nil
+	asyncHooks = nil
+}
+
+// fireHooks runs the registered hooks and reports whether the record is kept.
+// The hooks run without the registry lock held, so they may log.
+func fireHooks(record *Record) bool {
+	hookMutex.RLock()
+	hooks := append([]Hook(nil), syncHooks...)
+	async := append([]*asyncHook(nil), asyncHooks...)
+	hookMutex.RUnlock()
+	for _, hook := range hooks {
+		if record.Level < hook.Level() {
+			continue
+		}
+		if !hook.Fire(record) {
+			return false
+		}
+	}
+	for _, h := range async {
+		if record.Level < h.hook.Level() {
+			continue
+		}
+		h.enqueue(record)
+	}
+	return true
+}
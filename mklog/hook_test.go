@@ -0,0 +1,142 @@
+package mklog
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"runtime"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/zhongxuqi/mklibs/mkerr"
+)
+
+func TestHook(t *testing.T) {
+	defer ResetHooks()
+	dist := bytes.NewBuffer(nil)
+	ml := New()
+	ml.SetOutput(dist)
+
+	AddHook(HookFunc{
+		MinLevel: LevelInfo,
+		Func: func(record *Record) bool {
+			if record.Message == "drop" {
+				return false
+			}
+			if record.Fields == nil {
+				record.Fields = make(map[string]interface{})
+			}
+			record.Fields["env"] = "test"
+			return true
+		},
+	})
+	records := make(chan *Record, 10)
+	AddAsyncHook(HookFunc{
+		MinLevel: LevelError,
+		Func: func(record *Record) bool {
+			records <- record
+			return true
+		},
+	}, 10)
+
+	ml.Debugf("debug")
+	if !strings.HasSuffix(dist.String(), ":debug\n") {
+		t.Fatalf("Debugf print error %s", dist.String())
+	}
+	dist.Reset()
+	ml.Infof("drop")
+	if dist.Len() > 0 {
+		t.Fatalf("Infof should be dropped %s", dist.String())
+	}
+	dist.Reset()
+	err := mkerr.NewError(100, "not found", "user 1")
+	_, _, line, _ := runtime.Caller(0)
+	ml.WithField("uid", 1).Errorf("query error %+v", err)
+	if !strings.Contains(dist.String(), ":query error not found env=test errdetail=user 1 errmsg=not found errno=100 uid=1\n    ") ||
+		strings.Count(dist.String(), "user 1") != 1 || strings.Count(dist.String(), "mklog.TestHook") != 1 {
+		t.Fatalf("Errorf print error %s", dist.String())
+	}
+	FlushHooks()
+	if len(records) != 1 {
+		t.Fatalf("async hook error %+v", len(records))
+	}
+	record := <-records
+	if record.Err == nil || record.Err.ErrNo() != 100 || record.LogID != ml.GetLogID() || record.Fields["env"] != "test" ||
+		!strings.HasSuffix(record.Caller, fmt.Sprintf("hook_test.go:%d", line+1)) {
+		t.Fatalf("async hook data error %+v", record)
+	}
+}
+
+func TestHookConcurrency(t *testing.T) {
+	defer ResetHooks()
+	ml := New()
+	ml.SetOutput(ioutil.Discard)
+
+	// 同步hook中可以注册hook和打印日志
+	AddHook(HookFunc{
+		MinLevel: LevelInfo,
+		Func: func(record *Record) bool {
+			if record.Message == "register" {
+				AddHook(HookFunc{MinLevel: LevelError, Func: func(record *Record) bool { return true }})
+				ml.Infof("registered")
+			}
+			return true
+		},
+	})
+	ml.Infof("register")
+
+	var fired int64
+	AddAsyncHook(HookFunc{
+		MinLevel: LevelInfo,
+		Func: func(record *Record) bool {
+			atomic.AddInt64(&fired, 1)
+			return true
+		},
+	}, 1000)
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				ml.Infof("concurrent %d", j)
+				FlushHooks()
+			}
+		}()
+	}
+	wg.Wait()
+	FlushHooks()
+	if atomic.LoadInt64(&fired) == 0 {
+		t.Fatalf("async hook not fired")
+	}
+}
+
+func TestWebhook(t *testing.T) {
+	defer ResetHooks()
+	bodies := make(chan map[string]interface{}, 10)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := ioutil.ReadAll(r.Body)
+		body := make(map[string]interface{})
+		json.Unmarshal(b, &body)
+		bodies <- body
+	}))
+	defer server.Close()
+
+	AddHook(NewWebhook(server.URL, LevelError))
+	ml := New()
+	ml.SetOutput(bytes.NewBuffer(nil))
+	ml.Infof("info")
+	ml.Errorf("error %v", mkerr.NewError(100, "not found", "user 1"))
+	if len(bodies) != 1 {
+		t.Fatalf("webhook error %+v", len(bodies))
+	}
+	body := <-bodies
+	if body["msg"] != "error not found" || body["logid"] != ml.GetLogID() || body["level"] != "Error" || body["errno"] != float64(100) {
+		t.Fatalf("webhook data error %+v", body)
+	}
+}
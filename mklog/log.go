@@ -3,16 +3,20 @@ package mklog
 import (
 	"context"
 	"encoding/base64"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
 	"os"
 	"runtime"
+	"sort"
 	"time"
 
 	"github.com/google/uuid"
 	isatty "github.com/mattn/go-isatty"
 	"github.com/zhongxuqi/mklibs/common"
+	"github.com/zhongxuqi/mklibs/mkerr"
 )
 
 type Level int
@@ -24,6 +28,8 @@ const (
 
 	ContextLog = "mklog-instance"
 
+	TimeFormat = "2006-01-02T15:04:05.000Z07:00"
+
 	colorNone   = "\x1b[0m"
 	colorRed    = "\x1b[1;31m"
 	colorGreen  = "\x1b[1;32m"
@@ -51,8 +57,12 @@ var (
 type Logger interface {
 	SetOutput(writer io.Writer)
 	SetLevel(lvl Level)
+	SetFormat(format Format)
+	SetStackLevel(lvl Level)
 	Context() context.Context
 	GetLogID() string
+	WithField(key string, value interface{}) Logger
+	WithFields(fields map[string]interface{}) Logger
 	Debugf(format string, v ...interface{})
 	Infof(format string, v ...interface{})
 	Errorf(format string, v ...interface{})
@@ -62,15 +72,20 @@ type logger struct {
 	ctx    context.Context
 	writer io.Writer // output io
 	level  Level     // output level
+	format Format    // output format
 	logID  string    // log id
+	fields map[string]interface{}
+
+	stackLevel Level // lowest level printing the stack of mkerr errors
 }
 
 func New() Logger {
 	b := uuid.New()
 	return &logger{
-		ctx:   context.TODO(),
-		level: LevelDebug,
-		logID: base64.StdEncoding.EncodeToString(b[:]),
+		ctx:        context.TODO(),
+		level:      LevelDebug,
+		logID:      base64.StdEncoding.EncodeToString(b[:]),
+		stackLevel: LevelError,
 	}
 }
 
@@ -82,9 +97,10 @@ func NewWithReq(req *http.Request) Logger {
 		req.Header.Set(common.HttpLogID, logID)
 	}
 	return &logger{
-		ctx:   context.TODO(),
-		level: LevelDebug,
-		logID: logID,
+		ctx:        context.TODO(),
+		level:      LevelDebug,
+		logID:      logID,
+		stackLevel: LevelError,
 	}
 }
 
@@ -97,20 +113,11 @@ func NewWithContext(ctx context.Context) Logger {
 	}
 	b := uuid.New()
 	return &logger{
-		ctx:   context.TODO(),
-		level: LevelDebug,
-		logID: base64.StdEncoding.EncodeToString(b[:]),
-	}
-}
-
-func (s *logger) getPrefix(level Level) string {
-	_, file, line, _ := runtime.Caller(3)
-	if NoColor {
-		return fmt.Sprintf("%s[%s][%s]%s:%d:", time.Now().Format(time.RFC3339), LevelMap[level],
-			s.logID, file, line)
+		ctx:        context.TODO(),
+		level:      LevelDebug,
+		logID:      base64.StdEncoding.EncodeToString(b[:]),
+		stackLevel: LevelError,
 	}
-	return fmt.Sprintf("%s%s%s[%s]%s[%s]%s%s:%d:%s", colorBlue, time.Now().Format(time.RFC3339), LevelColorMap[level], LevelMap[level],
-		colorPurple, s.logID, colorBlue, file, line, colorNone)
 }
 
 func (s *logger) SetOutput(writer io.Writer) {
@@ -121,6 +128,14 @@ func (s *logger) SetLevel(lvl Level) {
 	s.level = lvl
 }
 
+func (s *logger) SetFormat(format Format) {
+	s.format = format
+}
+
+func (s *logger) SetStackLevel(lvl Level) {
+	s.stackLevel = lvl
+}
+
 func (s *logger) Context() context.Context {
 	return context.WithValue(s.ctx, ContextLog, s)
 }
@@ -129,6 +144,22 @@ func (s *logger) GetLogID() string {
 	return s.logID
 }
 
+func (s *logger) WithField(key string, value interface{}) Logger {
+	return s.WithFields(map[string]interface{}{key: value})
+}
+
+func (s *logger) WithFields(fields map[string]interface{}) Logger {
+	res := *s
+	res.fields = make(map[string]interface{}, len(s.fields)+len(fields))
+	for k, v := range s.fields {
+		res.fields[k] = v
+	}
+	for k, v := range fields {
+		res.fields[k] = v
+	}
+	return &res
+}
+
 func (s *logger) Debugf(format string, v ...interface{}) {
 	s.writeLog(LevelDebug, format, v...)
 }
@@ -149,8 +180,87 @@ func (s *logger) writeLog(lvl Level, format string, v ...interface{}) {
 	if out == nil {
 		out = os.Stdout
 	}
-	args := make([]interface{}, 0, len(v)+1)
-	args = append(args, s.getPrefix(lvl))
-	args = append(args, v...)
-	fmt.Fprintf(out, "%s"+format+"\n", args...)
+	_, file, line, _ := runtime.Caller(2)
+	mkErr, index := findError(s.fields, v)
+	args := v
+	if index >= 0 {
+		// the detail and stack of the error are logged as fields, the message only prints its message
+		args = append([]interface{}(nil), v...)
+		args[index] = messageError{v[index].(error)}
+	}
+	record := &Record{
+		Time:    time.Now(),
+		Level:   lvl,
+		LogID:   s.logID,
+		Caller:  fmt.Sprintf("%s:%d", file, line),
+		Message: fmt.Sprintf(format, args...),
+	}
+	if len(s.fields) > 0 {
+		record.Fields = make(map[string]interface{}, len(s.fields))
+		for k, v := range s.fields {
+			// error values are usually structs with unexported fields, which json renders as {}
+			if err, ok := v.(error); ok {
+				record.Fields[k] = err.Error()
+				continue
+			}
+			record.Fields[k] = v
+		}
+	}
+	if mkErr != nil {
+		record.setError(mkErr, lvl >= s.stackLevel)
+	}
+	if !fireHooks(record) {
+		return
+	}
+	if s.format == FormatJSON {
+		b, err := json.Marshal(record)
+		if err != nil {
+			fmt.Fprintf(out, "%s\n", record.FormatText(!NoColor))
+			return
+		}
+		fmt.Fprintf(out, "%s\n", b)
+		return
+	}
+	fmt.Fprintf(out, "%s\n", record.FormatText(!NoColor))
+}
+
+// findError returns the first mkerr.Error of the args, with its index, else of
+// the fields with index -1
+func findError(fields map[string]interface{}, v []interface{}) (mkerr.Error, int) {
+	for i, arg := range v {
+		if err, ok := arg.(error); ok {
+			var mkErr mkerr.Error
+			if errors.As(err, &mkErr) {
+				return mkErr, i
+			}
+		}
+	}
+	keys := make([]string, 0, len(fields))
+	for k := range fields {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		if err, ok := fields[k].(error); ok {
+			var mkErr mkerr.Error
+			if errors.As(err, &mkErr) {
+				return mkErr, -1
+			}
+		}
+	}
+	return nil, -1
+}
+
+// messageError formats an error with its message only, %+v included
+type messageError struct {
+	err error
+}
+
+func (s messageError) Format(state fmt.State, verb rune) {
+	switch verb {
+	case 'v', 's':
+		io.WriteString(state, s.err.Error())
+	default:
+		fmt.Fprintf(state, fmt.FormatString(state, verb), s.err.Error())
+	}
 }
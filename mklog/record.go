@@ -0,0 +1,338 @@
+package mklog
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/zhongxuqi/mklibs/mkerr"
+)
+
+type Format int
+
+const (
+	FormatText Format = 0
+	FormatJSON Format = 1
+
+	recordKeyTime   = "time"
+	recordKeyLevel  = "level"
+	recordKeyLogID  = "logid"
+	recordKeyCaller = "caller"
+	recordKeyMsg    = "msg"
+
+	// fields rendering the mkerr.Error attached to a record
+	FieldErrNo     = "errno"
+	FieldErrMsg    = "errmsg"
+	FieldErrDetail = "errdetail"
+	FieldStack     = "stack"
+)
+
+var (
+	ansiColorRegexp  = regexp.MustCompile("\x1b\\[[0-9;]*m")
+	textRecordRegexp = regexp.MustCompile(`^(\S+?)\[(\w+)\]\[([^\]]*)\](.*?:\d+):(.*)$`)
+	textFieldRegexp  = regexp.MustCompile(` ([A-Za-z_][\w.-]*)=`)
+)
+
+// Record is a single log line, as written by Logger and read back by ParseRecord.
+type Record struct {
+	Time    time.Time
+	Level   Level
+	LogID   string
+	Caller  string
+	Message string
+	Fields  map[string]interface{}
+	Err     mkerr.Error // the first mkerr.Error found in the arguments or fields
+}
+
+// setError attaches err to the record and renders it as the errno, errmsg,
+// errdetail and, when withStack is set, stack fields.
+func (s *Record) setError(err mkerr.Error, withStack bool) {
+	s.Err = err
+	if s.Fields == nil {
+		s.Fields = make(map[string]interface{})
+	}
+	s.Fields[FieldErrNo] = err.ErrNo()
+	s.Fields[FieldErrMsg] = err.Error()
+	detail, stack := splitErrDetail(err.ErrDetail())
+	var mkErr *mkerr.MkErr
+	if errors.As(err, &mkErr) {
+		frames := mkErr.StackTrace()
+		stack = make([]string, 0, len(frames))
+		for _, frame := range frames {
+			stack = append(stack, fmt.Sprintf("%s:%d %s", frame.File, frame.Line, frame.Function))
+		}
+	}
+	if detail != "" {
+		s.Fields[FieldErrDetail] = detail
+	}
+	if withStack && len(stack) > 0 {
+		s.Fields[FieldStack] = stack
+	}
+}
+
+// splitErrDetail splits the "error: detail" first line of ErrDetail from the
+// stack lines that follow it.
+func splitErrDetail(errDetail string) (string, []string) {
+	lines := strings.Split(errDetail, "\n")
+	stack := make([]string, 0, len(lines)-1)
+	for _, line := range lines[1:] {
+		if line = strings.TrimSpace(line); line != "" {
+			stack = append(stack, line)
+		}
+	}
+	return strings.TrimPrefix(lines[0], "error: "), stack
+}
+
+func (s *Record) clone() *Record {
+	res := *s
+	if s.Fields != nil {
+		res.Fields = make(map[string]interface{}, len(s.Fields))
+		for k, v := range s.Fields {
+			res.Fields[k] = v
+		}
+	}
+	return &res
+}
+
+func ParseLevel(name string) (Level, error) {
+	for lvl, lvlName := range LevelMap {
+		if strings.EqualFold(lvlName, name) {
+			return lvl, nil
+		}
+	}
+	return LevelDebug, fmt.Errorf("unknown level %s", name)
+}
+
+func (s *Record) MarshalJSON() ([]byte, error) {
+	m := make(map[string]interface{}, len(s.Fields)+5)
+	for k, v := range s.Fields {
+		m[k] = v
+	}
+	m[recordKeyTime] = s.Time.Format(TimeFormat)
+	m[recordKeyLevel] = LevelMap[s.Level]
+	m[recordKeyLogID] = s.LogID
+	m[recordKeyCaller] = s.Caller
+	m[recordKeyMsg] = s.Message
+	return json.Marshal(m)
+}
+
+func (s *Record) UnmarshalJSON(b []byte) error {
+	m := make(map[string]interface{})
+	if err := json.Unmarshal(b, &m); err != nil {
+		return err
+	}
+	timeStr, _ := m[recordKeyTime].(string)
+	levelStr, _ := m[recordKeyLevel].(string)
+	if timeStr == "" || levelStr == "" {
+		return fmt.Errorf("not a mklog record")
+	}
+	t, err := time.Parse(time.RFC3339Nano, timeStr)
+	if err != nil {
+		return err
+	}
+	lvl, err := ParseLevel(levelStr)
+	if err != nil {
+		return err
+	}
+	s.Time = t
+	s.Level = lvl
+	s.LogID, _ = m[recordKeyLogID].(string)
+	s.Caller, _ = m[recordKeyCaller].(string)
+	s.Message, _ = m[recordKeyMsg].(string)
+	for _, k := range []string{recordKeyTime, recordKeyLevel, recordKeyLogID, recordKeyCaller, recordKeyMsg} {
+		delete(m, k)
+	}
+	s.Fields = nil
+	if len(m) > 0 {
+		s.Fields = m
+	}
+	return nil
+}
+
+// FormatText renders the record the way Logger writes it in FormatText.
+func (s *Record) FormatText(color bool) string {
+	fields := s.formatFields()
+	if !color {
+		return fmt.Sprintf("%s[%s][%s]%s:%s%s", s.Time.Format(TimeFormat), LevelMap[s.Level],
+			s.LogID, s.Caller, s.Message, fields)
+	}
+	return fmt.Sprintf("%s%s%s[%s]%s[%s]%s%s:%s%s%s", colorBlue, s.Time.Format(TimeFormat), LevelColorMap[s.Level], LevelMap[s.Level],
+		colorPurple, s.LogID, colorBlue, s.Caller, colorNone, s.Message, fields)
+}
+
+func (s *Record) formatFields() string {
+	if len(s.Fields) == 0 {
+		return ""
+	}
+	keys := make([]string, 0, len(s.Fields))
+	for k := range s.Fields {
+		if k != FieldStack {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+	buf := bytes.NewBuffer(nil)
+	for _, k := range keys {
+		fmt.Fprintf(buf, " %s=%+v", k, s.Fields[k])
+	}
+	// the stack is printed one frame per line, like mkerr ErrDetail
+	switch stack := s.Fields[FieldStack].(type) {
+	case []string:
+		for _, frame := range stack {
+			fmt.Fprintf(buf, "\n    %s", frame)
+		}
+	case []interface{}:
+		for _, frame := range stack {
+			fmt.Fprintf(buf, "\n    %+v", frame)
+		}
+	}
+	return buf.String()
+}
+
+// ParseRecord parses one line written by Logger in either FormatJSON or FormatText.
+func ParseRecord(line string) (*Record, error) {
+	line = strings.TrimRight(line, "\r\n")
+	if strings.HasPrefix(strings.TrimSpace(line), "{") {
+		record := &Record{}
+		if err := json.Unmarshal([]byte(line), record); err != nil {
+			return nil, err
+		}
+		return record, nil
+	}
+	matches := textRecordRegexp.FindStringSubmatch(ansiColorRegexp.ReplaceAllString(line, ""))
+	if matches == nil {
+		return nil, fmt.Errorf("not a mklog record")
+	}
+	t, err := time.Parse(time.RFC3339Nano, matches[1])
+	if err != nil {
+		return nil, err
+	}
+	lvl, err := ParseLevel(matches[2])
+	if err != nil {
+		return nil, err
+	}
+	message, fields := splitTextFields(matches[5])
+	return &Record{
+		Time:    t,
+		Level:   lvl,
+		LogID:   matches[3],
+		Caller:  matches[4],
+		Message: message,
+		Fields:  fields,
+	}, nil
+}
+
+// splitTextFields splits the " key=value" fields FormatText appends to the
+// message. As the keys are written sorted, the fields are the longest suffix of
+// the text whose keys are in ascending order. The values are parsed as strings.
+func splitTextFields(text string) (string, map[string]interface{}) {
+	matches := textFieldRegexp.FindAllStringSubmatchIndex(text, -1)
+	start := len(matches)
+	for start > 0 && (start == len(matches) ||
+		text[matches[start-1][2]:matches[start-1][3]] < text[matches[start][2]:matches[start][3]]) {
+		start--
+	}
+	if start == len(matches) {
+		return text, nil
+	}
+	fields := make(map[string]interface{}, len(matches)-start)
+	for i := start; i < len(matches); i++ {
+		end := len(text)
+		if i+1 < len(matches) {
+			end = matches[i+1][0]
+		}
+		fields[text[matches[i][2]:matches[i][3]]] = text[matches[i][1]:end]
+	}
+	return text[:matches[start][0]], fields
+}
+
+// CallerLine returns the file and line parts of the record caller.
+func (s *Record) CallerLine() (string, int) {
+	idx := strings.LastIndex(s.Caller, ":")
+	if idx < 0 {
+		return s.Caller, 0
+	}
+	line, err := strconv.Atoi(s.Caller[idx+1:])
+	if err != nil {
+		return s.Caller, 0
+	}
+	return s.Caller[:idx], line
+}
+
+// appendLine adds a continuation line of a text record: a frame of the stack
+// once the fields are parsed, else a line of the message ending with the fields.
+func (s *Record) appendLine(line string) {
+	if s.Fields != nil && strings.HasPrefix(line, "    ") {
+		stack, _ := s.Fields[FieldStack].([]string)
+		s.Fields[FieldStack] = append(stack, strings.TrimSpace(line))
+		return
+	}
+	if s.Fields == nil {
+		text, fields := splitTextFields(line)
+		s.Message += "\n" + text
+		s.Fields = fields
+		return
+	}
+	s.Message += "\n" + line
+}
+
+// RecordReader reads records from a log stream. Text lines that are not a record
+// header, e.g. a message containing newlines, are appended to the previous record.
+type RecordReader struct {
+	reader  *bufio.Reader
+	pending *Record
+}
+
+func NewRecordReader(r io.Reader) *RecordReader {
+	return &RecordReader{
+		reader: bufio.NewReader(r),
+	}
+}
+
+// Next returns the next record, or io.EOF when the stream is drained.
+func (s *RecordReader) Next() (*Record, error) {
+	for {
+		line, err := s.reader.ReadString('\n')
+		if len(line) > 0 {
+			if record := s.Add(line); record != nil {
+				return record, nil
+			}
+		}
+		if err != nil {
+			if record := s.Flush(); record != nil {
+				return record, nil
+			}
+			return nil, err
+		}
+	}
+}
+
+// Add adds a line of the stream, for callers reading the lines themselves. It
+// returns the previous record when the line starts a new one, else nil.
+func (s *RecordReader) Add(line string) *Record {
+	record, err := ParseRecord(line)
+	if err != nil {
+		if s.pending != nil {
+			s.pending.appendLine(strings.TrimRight(ansiColorRegexp.ReplaceAllString(line, ""), "\r\n"))
+		}
+		return nil
+	}
+	res := s.pending
+	s.pending = record
+	return res
+}
+
+// Flush returns the record being read, nil when there is none.
+func (s *RecordReader) Flush() *Record {
+	res := s.pending
+	s.pending = nil
+	return res
+}
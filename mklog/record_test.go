@@ -0,0 +1,110 @@
+package mklog
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/zhongxuqi/mklibs/mkerr"
+)
+
+func TestParseRecord(t *testing.T) {
+	dist := bytes.NewBuffer(nil)
+	ml := New()
+	ml.SetOutput(dist)
+	_, _, line, _ := runtime.Caller(0)
+	ml.Infof("text line")
+	ml.SetFormat(FormatJSON)
+	ml.Errorf("json line")
+
+	reader := NewRecordReader(dist)
+	record, err := reader.Next()
+	if err != nil {
+		t.Fatalf("reader.Next error %+v", err)
+	} else if record.Level != LevelInfo || record.Message != "text line" || record.LogID != ml.GetLogID() ||
+		!strings.HasSuffix(record.Caller, fmt.Sprintf("record_test.go:%d", line+1)) {
+		t.Fatalf("record data error %+v", record)
+	}
+	record, err = reader.Next()
+	if err != nil {
+		t.Fatalf("reader.Next error %+v", err)
+	} else if record.Level != LevelError || record.Message != "json line" || record.LogID != ml.GetLogID() {
+		t.Fatalf("record data error %+v", record)
+	}
+	if _, err = reader.Next(); err != io.EOF {
+		t.Fatalf("reader.Next error %+v", err)
+	}
+}
+
+func TestRecordReaderMultiLine(t *testing.T) {
+	colored := (&Record{Level: LevelError, LogID: "id", Caller: "a.go:1", Message: "first"}).FormatText(true)
+	reader := NewRecordReader(bytes.NewBufferString(colored + "\nsecond\n" + colored + "\n"))
+	record, err := reader.Next()
+	if err != nil {
+		t.Fatalf("reader.Next error %+v", err)
+	} else if record.Message != "first\nsecond" || record.LogID != "id" || record.Caller != "a.go:1" {
+		t.Fatalf("record data error %+v", record)
+	}
+	if record, err = reader.Next(); err != nil || record.Message != "first" {
+		t.Fatalf("reader.Next error %+v %+v", record, err)
+	}
+
+	// 文本格式的字段和堆栈
+	withFields := (&Record{Level: LevelError, LogID: "id", Caller: "a.go:1", Message: "query user", Fields: map[string]interface{}{
+		FieldErrDetail: "user 1", FieldErrNo: 100, "uid": 7, FieldStack: []string{"a.go:1 main.a", "b.go:2 main.b"},
+	}}).FormatText(true)
+	reader = NewRecordReader(bytes.NewBufferString(withFields + "\n" + colored + "\n"))
+	if record, err = reader.Next(); err != nil {
+		t.Fatalf("reader.Next error %+v", err)
+	} else if record.Message != "query user" || record.Fields[FieldErrDetail] != "user 1" || record.Fields[FieldErrNo] != "100" ||
+		record.Fields["uid"] != "7" || fmt.Sprint(record.Fields[FieldStack]) != "[a.go:1 main.a b.go:2 main.b]" {
+		t.Fatalf("record data error %+v", record)
+	}
+}
+
+func TestErrorFields(t *testing.T) {
+	dist := bytes.NewBuffer(nil)
+	ml := New()
+	ml.SetOutput(dist)
+	ml.SetFormat(FormatJSON)
+	err := mkerr.NewError(100, "not found", "user 1")
+
+	ml.WithField("cause", err).Infof("query user")
+	record, parseErr := ParseRecord(dist.String())
+	if parseErr != nil {
+		t.Fatalf("ParseRecord error %+v", parseErr)
+	} else if record.Fields[FieldErrNo] != float64(100) || record.Fields[FieldErrMsg] != "not found" ||
+		record.Fields[FieldErrDetail] != "user 1" || record.Fields["cause"] != "not found" || record.Fields[FieldStack] != nil {
+		t.Fatalf("record data error %+v", record)
+	}
+
+	dist.Reset()
+	ml.Errorf("query user error %+v", err)
+	record, parseErr = ParseRecord(dist.String())
+	if parseErr != nil {
+		t.Fatalf("ParseRecord error %+v", parseErr)
+	} else if stack, ok := record.Fields[FieldStack].([]interface{}); !ok || len(stack) == 0 || record.Message != "query user error not found" {
+		t.Fatalf("record data error %+v", record)
+	}
+
+	// %+v 的错误详情和堆栈只在字段中打印一次
+	dist.Reset()
+	ml.SetFormat(FormatText)
+	ml.Errorf("query user error %+v", err)
+	if s := dist.String(); strings.Count(s, "user 1") != 1 || strings.Count(s, "mklog.TestErrorFields") != 1 {
+		t.Fatalf("Errorf print error %s", s)
+	}
+	ml.SetFormat(FormatJSON)
+
+	dist.Reset()
+	ml.SetStackLevel(LevelDebug)
+	ml.SetFormat(FormatText)
+	ml.Debugf("query user error %+v", fmt.Errorf("wrapped: %w", err))
+	if lines := strings.Split(strings.TrimSpace(dist.String()), "\n"); len(lines) < 2 ||
+		!strings.HasSuffix(lines[0], "errdetail=user 1 errmsg=not found errno=100") || !strings.HasPrefix(lines[1], "    ") {
+		t.Fatalf("Debugf print error %s", dist.String())
+	}
+}
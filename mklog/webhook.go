@@ -0,0 +1,78 @@
+package mklog
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"time"
+)
+
+// Webhook is a Hook posting every record as a JSON object to an HTTP endpoint.
+// It is usually registered with AddAsyncHook so that logging does not wait for
+// the endpoint.
+type Webhook struct {
+	URL      string
+	MinLevel Level
+	Header   http.Header
+	Client   *http.Client
+	ErrOut   io.Writer // where delivery failures are reported, os.Stderr by default
+}
+
+func NewWebhook(url string, level Level) *Webhook {
+	return &Webhook{
+		URL:      url,
+		MinLevel: level,
+		Header:   make(http.Header),
+		Client: &http.Client{
+			Timeout: 5 * time.Second,
+		},
+	}
+}
+
+func (s *Webhook) Level() Level {
+	return s.MinLevel
+}
+
+func (s *Webhook) Fire(record *Record) bool {
+	if err := s.post(record); err != nil {
+		errOut := s.ErrOut
+		if errOut == nil {
+			errOut = os.Stderr
+		}
+		fmt.Fprintf(errOut, "mklog webhook %s error %+v\n", s.URL, err)
+	}
+	return true
+}
+
+func (s *Webhook) post(record *Record) error {
+	b, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest(http.MethodPost, s.URL, bytes.NewReader(b))
+	if err != nil {
+		return err
+	}
+	for k, v := range s.Header {
+		req.Header[k] = v
+	}
+	req.Header.Set("Content-Type", "application/json")
+	client := s.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	res, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	io.Copy(ioutil.Discard, res.Body)
+	if res.StatusCode/100 != 2 {
+		return fmt.Errorf("http error %d %s", res.StatusCode, res.Status)
+	}
+	return nil
+}
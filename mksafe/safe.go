@@ -0,0 +1,84 @@
+// Package mksafe runs functions and goroutines converting their panics into
+// mkerr errors, logged through mklog with the log id of the context.
+package mksafe
+
+import (
+	"context"
+	"sync"
+
+	"github.com/zhongxuqi/mklibs/mkerr"
+	"github.com/zhongxuqi/mklibs/mklog"
+)
+
+// Run calls fn and returns its error. A panic of fn is recovered, logged and
+// returned as an mkerr.Error with errno mkerr.ErrNoPanic.
+func Run(ctx context.Context, fn func(ctx context.Context) error) (err error) {
+	defer func() {
+		if v := recover(); v != nil {
+			panicErr := mkerr.FromPanic(v)
+			mklog.NewWithContext(ctx).Errorf("recovered %v", panicErr)
+			err = panicErr
+		}
+	}()
+	return fn(ctx)
+}
+
+// Go calls fn with Run in a new goroutine. The returned channel receives its
+// error, nil included, and is then closed.
+func Go(ctx context.Context, fn func(ctx context.Context) error) <-chan error {
+	res := make(chan error, 1)
+	go func() {
+		defer close(res)
+		res <- Run(ctx, fn)
+	}()
+	return res
+}
+
+// Group runs functions in goroutines with Run and waits for them, like
+// golang.org/x/sync/errgroup.Group. The zero Group is valid and does not cancel.
+type Group struct {
+	ctx     context.Context
+	cancel  context.CancelFunc
+	wg      sync.WaitGroup
+	errOnce sync.Once
+	err     error
+}
+
+// WithContext returns a Group whose context is canceled when a function returns
+// an error or panics, or when Wait returns.
+func WithContext(ctx context.Context) (*Group, context.Context) {
+	ctx, cancel := context.WithCancel(ctx)
+	return &Group{
+		ctx:    ctx,
+		cancel: cancel,
+	}, ctx
+}
+
+// Go calls fn in a new goroutine with the context of the group.
+func (s *Group) Go(fn func(ctx context.Context) error) {
+	ctx := s.ctx
+	if ctx == nil {
+		ctx = context.TODO()
+	}
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		if err := Run(ctx, fn); err != nil {
+			s.errOnce.Do(func() {
+				s.err = err
+				if s.cancel != nil {
+					s.cancel()
+				}
+			})
+		}
+	}()
+}
+
+// Wait blocks until every function returned, and returns the first error.
+func (s *Group) Wait() error {
+	s.wg.Wait()
+	if s.cancel != nil {
+		s.cancel()
+	}
+	return s.err
+}
@@ -0,0 +1,75 @@
+package mksafe
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/zhongxuqi/mklibs/mkerr"
+	"github.com/zhongxuqi/mklibs/mklog"
+)
+
+func TestRun(t *testing.T) {
+	dist := bytes.NewBuffer(nil)
+	ml := mklog.New()
+	ml.SetOutput(dist)
+	ctx := ml.Context()
+
+	if err := Run(ctx, func(ctx context.Context) error { return io.EOF }); err != io.EOF {
+		t.Fatalf("Run error %+v", err)
+	}
+	err := Run(ctx, func(ctx context.Context) error {
+		var m map[string]int
+		m["key"] = 1
+		return nil
+	})
+	var mkErr *mkerr.MkErr
+	if !errors.As(err, &mkErr) || mkErr.ErrNo() != mkerr.ErrNoPanic || !mkerr.IsInternal(err) ||
+		!strings.Contains(err.Error(), "assignment to entry in nil map") {
+		t.Fatalf("Run error %+v", err)
+	}
+	if frames := mkErr.StackTrace(); len(frames) < 2 || !strings.Contains(frames[1].Function, "TestRun") {
+		t.Fatalf("Run stack error %+v", frames)
+	}
+	var runtimeErr interface{ RuntimeError() }
+	if !errors.As(err, &runtimeErr) {
+		t.Fatalf("Run cause error %+v", err)
+	}
+	if !strings.Contains(dist.String(), "["+ml.GetLogID()+"]") || !strings.Contains(dist.String(), "recovered panic: ") {
+		t.Fatalf("Run log error %s", dist.String())
+	}
+
+	errChan := Go(ctx, func(ctx context.Context) error { panic("boom") })
+	if err := <-errChan; err == nil || err.Error() != "panic: boom" {
+		t.Fatalf("Go error %+v", err)
+	}
+	if _, ok := <-errChan; ok {
+		t.Fatalf("Go channel should be closed")
+	}
+}
+
+func TestGroup(t *testing.T) {
+	ml := mklog.New()
+	ml.SetOutput(bytes.NewBuffer(nil))
+	g, ctx := WithContext(ml.Context())
+	g.Go(func(ctx context.Context) error { panic("boom") })
+	g.Go(func(ctx context.Context) error {
+		<-ctx.Done()
+		return ctx.Err()
+	})
+	if err := g.Wait(); err == nil || err.Error() != "panic: boom" {
+		t.Fatalf("Wait error %+v", err)
+	}
+	if ctx.Err() == nil {
+		t.Fatalf("group context should be canceled")
+	}
+
+	var zero Group
+	zero.Go(func(ctx context.Context) error { return nil })
+	if err := zero.Wait(); err != nil {
+		t.Fatalf("Wait error %+v", err)
+	}
+}